@@ -1,10 +1,20 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/docker/machine/libmachine/drivers/plugin"
 	"github.com/yunify/docker-machine-driver-qingcloud/qingcloud"
 )
 
 func main() {
+	if len(os.Args) > 1 && qingcloud.IsCommand(os.Args[1]) {
+		if err := qingcloud.RunCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	plugin.RegisterDriver(qingcloud.NewDriver("", ""))
 }
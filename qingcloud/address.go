@@ -0,0 +1,62 @@
+package qingcloud
+
+import (
+	"errors"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// ipRefreshInterval limits how often GetIP asks the API for the address.
+const ipRefreshInterval = time.Minute
+
+// instanceAddress returns the address docker-machine uses for the instance:
+// the private IP of qingcloud-primary-vxnet, the EIP, or the private IP with
+// qingcloud-use-private-address or when the instance has no EIP.
+func (d *Driver) instanceAddress(ins *qcservice.Instance) string {
+	if d.PrimaryVxNet != "" && d.PrimaryVxNet != d.VxNet {
+		for _, vxnet := range ins.VxNets {
+			if vxnet.VxNetID != nil && *vxnet.VxNetID == d.PrimaryVxNet && vxnet.PrivateIP != nil {
+				return *vxnet.PrivateIP
+			}
+		}
+		return ""
+	}
+	if !d.UsePrivateAddress && ins.EIP != nil && ins.EIP.EIPAddr != nil && *ins.EIP.EIPAddr != "" {
+		return *ins.EIP.EIPAddr
+	}
+	if len(ins.VxNets) > 0 && ins.VxNets[0].PrivateIP != nil {
+		return *ins.VxNets[0].PrivateIP
+	}
+	return ""
+}
+
+// GetIP returns the address of the instance. The stored address is checked
+// against the instance, e.g. when the EIP was swapped in the console.
+func (d *Driver) GetIP() (string, error) {
+	if d.InstanceID != nil && d.AccessKeyID != "" && time.Since(d.ipRefreshed) > ipRefreshInterval {
+		d.refreshIP()
+	}
+	if d.IPAddress == "" {
+		return "", errors.New("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+// refreshIP updates the stored address from the instance, the stored address
+// is kept if the instance can not be described.
+func (d *Driver) refreshIP() {
+	ins, err := d.getInstance()
+	if err != nil {
+		log.Debugf("Refresh IP of Instance [%s] fail, err: [%s]", *d.InstanceID, err.Error())
+		return
+	}
+	d.ipRefreshed = time.Now()
+	ip := d.instanceAddress(ins)
+	if ip == "" || ip == d.IPAddress {
+		return
+	}
+	log.Infof("IP address of Instance [%s] changed from [%s] to [%s]", *d.InstanceID, d.IPAddress, ip)
+	d.IPAddress = ip
+}
@@ -0,0 +1,68 @@
+package qingcloud
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestInstanceAddress(t *testing.T) {
+	ins := &qcservice.Instance{
+		VxNets: []*qcservice.VxNet{{PrivateIP: stringPtr("192.168.0.2")}},
+		EIP:    &qcservice.EIP{EIPAddr: stringPtr("139.198.1.1")},
+	}
+	d := NewDriver("test", "")
+	if ip := d.instanceAddress(ins); ip != "139.198.1.1" {
+		t.Errorf("expect EIP, got [%s]", ip)
+	}
+	d.UsePrivateAddress = true
+	if ip := d.instanceAddress(ins); ip != "192.168.0.2" {
+		t.Errorf("expect private IP with use-private-address, got [%s]", ip)
+	}
+	d.UsePrivateAddress = false
+	ins.EIP = &qcservice.EIP{EIPAddr: stringPtr("")}
+	if ip := d.instanceAddress(ins); ip != "192.168.0.2" {
+		t.Errorf("expect private IP without EIP, got [%s]", ip)
+	}
+	if ip := d.instanceAddress(&qcservice.Instance{}); ip != "" {
+		t.Errorf("expect no IP, got [%s]", ip)
+	}
+	d.PrimaryVxNet = "vxnet-data"
+	ins = &qcservice.Instance{
+		VxNets: []*qcservice.VxNet{
+			{VxNetID: stringPtr("vxnet-0"), PrivateIP: stringPtr("10.0.0.2")},
+			{VxNetID: stringPtr("vxnet-data"), PrivateIP: stringPtr("192.168.100.10")},
+		},
+		EIP: &qcservice.EIP{EIPAddr: stringPtr("139.198.1.1")},
+	}
+	if ip := d.instanceAddress(ins); ip != "192.168.100.10" {
+		t.Errorf("expect IP of primary vxnet, got [%s]", ip)
+	}
+}
+
+func TestGetIPRefreshesStaleAddress(t *testing.T) {
+	ins := &qcservice.Instance{
+		InstanceID: stringPtr("i-test"),
+		Status:     stringPtr(INSTANCE_STATUS_RUNNING),
+		EIP:        &qcservice.EIP{EIPID: stringPtr("eip-new"), EIPAddr: stringPtr("2.2.2.2")},
+	}
+	c := newFakeClient(ins)
+	d := newFakeDriver(c, "i-test")
+	d.AccessKeyID = "key"
+	d.IPAddress = "1.1.1.1"
+	if ip, err := d.GetIP(); err != nil || ip != "2.2.2.2" {
+		t.Errorf("expect the swapped EIP 2.2.2.2, but get %s, %v", ip, err)
+	}
+	ins.EIP.EIPAddr = stringPtr("3.3.3.3")
+	if ip, _ := d.GetIP(); ip != "2.2.2.2" || len(c.calls) != 1 {
+		t.Errorf("expect no refresh within the interval, but get %s and calls %v", ip, c.calls)
+	}
+
+	d.ipRefreshed = time.Time{}
+	c.errs["DescribeInstance"] = errors.New("api error")
+	if ip, err := d.GetIP(); err != nil || ip != "2.2.2.2" {
+		t.Errorf("expect the stored address kept when describe fails, but get %s, %v", ip, err)
+	}
+}
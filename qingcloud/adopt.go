@@ -0,0 +1,114 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// checkAdopt returns an error for the params of a created machine, which are
+// not applied to an adopted instance.
+func (d *Driver) checkAdopt() error {
+	errs := multiError{}
+	if d.SSHKeyPath == "" {
+		errs = append(errs, errors.New("Param error: qingcloud-instance-id param should work with qingcloud-ssh-keypath param."))
+	}
+	unsupported := map[string]bool{
+		"qingcloud-ttl":            d.TTL != "",
+		"qingcloud-extra-keypair":  len(d.ExtraKeyPairs) > 0,
+		"qingcloud-dns-alias":      d.DNSAliasPrefix != "",
+		"qingcloud-swarm-lb":       d.SwarmLB != "",
+		"qingcloud-extra-nic":      len(d.ExtraNics) > 0,
+		"qingcloud-shared-storage": len(d.SharedStorage) > 0,
+	}
+	names := []string{}
+	for name, set := range unsupported {
+		if set {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		errs = append(errs, fmt.Errorf("Param error: %s param can not work with qingcloud-instance-id param.", name))
+	}
+	return errs.errorOrNil()
+}
+
+// adoptInstance fills the driver from an existing instance instead of running
+// a new one. The local ssh key must match one of the instance keypairs.
+func (d *Driver) adoptInstance() error {
+	client := d.GetClient()
+	ins, err := d.getInstance()
+	if err != nil {
+		return err
+	}
+	if ins.Status == nil {
+		return fmt.Errorf("Instance [%s] has no status.", *d.InstanceID)
+	}
+	switch *ins.Status {
+	case INSTANCE_STATUS_RUNNING:
+	case INSTANCE_STATUS_STOPPED:
+		log.Infof("Starting Instance [%s]...", *d.InstanceID)
+		if err := d.Start(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Instance [%s] with status [%s] can not be adopted.", *d.InstanceID, *ins.Status)
+	}
+
+	publicKey, err := d.readPublicKey()
+	if err != nil {
+		return err
+	}
+	keyPairIDs := []string{}
+	d.LoginKeyPair = ""
+	for _, keyPairID := range ins.KeyPairIDs {
+		keyPairIDs = append(keyPairIDs, *keyPairID)
+		keyPair, err := client.DescribeKeyPair(keyPairID)
+		if err != nil {
+			return err
+		}
+		if keyPair.PubKey != nil && samePublicKey(publicKey, *keyPair.PubKey) {
+			d.LoginKeyPair = *keyPairID
+			break
+		}
+	}
+	if d.LoginKeyPair == "" {
+		return fmt.Errorf("SSH key [%s] doesn't match any keypair of Instance [%s], keypairs: [%s]",
+			d.publicSSHKeyPath(), *d.InstanceID, strings.Join(keyPairIDs, ", "))
+	}
+
+	if len(ins.VxNets) == 0 || ins.VxNets[0] == nil || ins.VxNets[0].VxNetID == nil {
+		return fmt.Errorf("Instance [%s] is not in any vxnet and can not be reached.", *d.InstanceID)
+	}
+	if ins.VxNets[0].PrivateIP == nil {
+		return fmt.Errorf("Instance [%s] has no IP address.", *d.InstanceID)
+	}
+	d.VxNet = *ins.VxNets[0].VxNetID
+	if ins.EIP != nil && ins.EIP.EIPAddr != nil && *ins.EIP.EIPAddr != "" {
+		d.EIP = ins.EIP
+	}
+	d.IPAddress = d.instanceAddress(ins)
+	d.ipRefreshed = time.Now()
+	if ins.SecurityGroup != nil && ins.SecurityGroup.SecurityGroupID != nil && *ins.SecurityGroup.SecurityGroupID != "" {
+		d.SecurityGroup = ins.SecurityGroup
+	}
+	if ins.ImageID != nil {
+		d.Image = *ins.ImageID
+	}
+	if ins.VCPUsCurrent != nil {
+		d.CPU = *ins.VCPUsCurrent
+	}
+	if ins.MemoryCurrent != nil {
+		d.Memory = *ins.MemoryCurrent
+	}
+	d.MachineName = *d.InstanceID
+
+	log.Infof("Adopted Instance [%s] IPAddress: [%s] LoginKeyPair: [%s]",
+		*d.InstanceID, d.IPAddress, d.LoginKeyPair)
+	return d.checkOSEnv()
+}
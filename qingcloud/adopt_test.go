@@ -0,0 +1,109 @@
+package qingcloud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// fakeFlags is a drivers.DriverOptions of flag values, unset flags are zero.
+type fakeFlags map[string]interface{}
+
+func (f fakeFlags) String(key string) string {
+	v, _ := f[key].(string)
+	return v
+}
+
+func (f fakeFlags) StringSlice(key string) []string {
+	v, _ := f[key].([]string)
+	return v
+}
+
+func (f fakeFlags) Int(key string) int {
+	v, _ := f[key].(int)
+	return v
+}
+
+func (f fakeFlags) Bool(key string) bool {
+	v, _ := f[key].(bool)
+	return v
+}
+
+func TestAdoptDetachOnRemove(t *testing.T) {
+	cases := []struct {
+		flags  fakeFlags
+		detach bool
+	}{
+		{fakeFlags{}, false},
+		{fakeFlags{"qingcloud-detach-on-remove": true}, true},
+		{fakeFlags{"qingcloud-instance-id": "i-test"}, true},
+		{fakeFlags{"qingcloud-instance-id": "i-test", "qingcloud-terminate-adopted": true}, false},
+	}
+	for _, c := range cases {
+		d := NewDriver("test", "")
+		if err := d.SetConfigFromFlags(c.flags); err != nil {
+			t.Fatal(err)
+		}
+		if d.DetachOnRemove != c.detach {
+			t.Errorf("expect detach on remove %v with flags %v, but get %v", c.detach, c.flags, d.DetachOnRemove)
+		}
+	}
+}
+
+func TestAdoptInstanceWithoutVxNet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adopt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyPath := filepath.Join(dir, "id_rsa")
+	if err := ioutil.WriteFile(keyPath+".pub", []byte(testPublicKeyA+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, vxnets := range [][]*qcservice.VxNet{nil, {nil}, {{PrivateIP: stringPtr("192.168.0.2")}}} {
+		c := newFakeClient(&qcservice.Instance{
+			InstanceID: stringPtr("i-test"),
+			Status:     stringPtr(INSTANCE_STATUS_RUNNING),
+			KeyPairIDs: []*string{stringPtr("kp-test")},
+			VxNets:     vxnets,
+		})
+		c.keyPairs["kp-test"] = &qcservice.KeyPair{KeyPairID: stringPtr("kp-test"), PubKey: stringPtr(testPublicKeyA)}
+		d := newFakeDriver(c, "i-test")
+		d.SSHKeyPath = keyPath
+		err := d.adoptInstance()
+		if err == nil || !strings.Contains(err.Error(), "not in any vxnet") {
+			t.Errorf("expect vxnet error of vxnets %v, but get %v", vxnets, err)
+		}
+	}
+}
+
+func TestAdoptRejectsCreateParams(t *testing.T) {
+	d := NewDriver("test", "")
+	err := d.SetConfigFromFlags(fakeFlags{
+		"qingcloud-instance-id":      "i-test",
+		"qingcloud-ssh-keypath":      "/tmp/id_rsa",
+		"qingcloud-ttl":              "8h",
+		"qingcloud-extra-keypair":    []string{"kp-extra"},
+		"qingcloud-shared-storage":   []string{"s2-target"},
+		"qingcloud-detach-on-remove": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.PreCreateCheck()
+	if err == nil {
+		t.Fatal("expect error for params which are not applied to an adopted instance")
+	}
+	for _, name := range []string{"qingcloud-ttl", "qingcloud-extra-keypair", "qingcloud-shared-storage"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expect error for %s, but get %s", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "qingcloud-ssh-keypath") {
+		t.Errorf("expect no error for qingcloud-ssh-keypath, but get %s", err)
+	}
+}
@@ -0,0 +1,167 @@
+package qingcloud
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	localhost = "127.0.0.1"
+	// localhostName is the host of the forwarded docker url, the docker
+	// certificate of libmachine is valid for the instance ip and localhost.
+	localhostName = "localhost"
+)
+
+// bastion is a jump host given as user@host[:port].
+type bastion struct {
+	User string
+	Host string
+	Port int
+}
+
+func parseBastion(s string) (*bastion, error) {
+	b := &bastion{User: "root", Port: 22}
+	b.Host = s
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		b.User, b.Host = s[:i], s[i+1:]
+	}
+	if host, port, err := net.SplitHostPort(b.Host); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("Invalid bastion port [%s].", port)
+		}
+		b.Host, b.Port = host, p
+	}
+	if b.User == "" || b.Host == "" || strings.Contains(b.Host, ":") {
+		return nil, fmt.Errorf("Invalid bastion [%s], expect user@host[:port].", s)
+	}
+	return b, nil
+}
+
+func (b *bastion) String() string {
+	return fmt.Sprintf("%s@%s:%d", b.User, b.Host, b.Port)
+}
+
+// freeLocalPort returns a local tcp port nobody listens on.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", localhost+":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func listening(port int) bool {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", localhost, port), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// tunnelUp returns true if the ssh master process of the tunnel answers on
+// its control socket. A stale socket left by a dead process is removed, so a
+// new master can be started on it.
+func (d *Driver) tunnelUp(sshBinary string, b *bastion, localPort int) bool {
+	controlPath := d.tunnelControlPath(localPort)
+	if _, err := os.Stat(controlPath); err != nil {
+		return false
+	}
+	args := append(d.bastionArgs(b), "-S", controlPath, "-O", "check", fmt.Sprintf("%s@%s", b.User, b.Host))
+	if err := exec.Command(sshBinary, args...).Run(); err != nil {
+		log.Debugf("Tunnel on local port [%d] is down, err: [%s]", localPort, err.Error())
+		os.Remove(controlPath)
+		return false
+	}
+	return true
+}
+
+// tunnelControlPath is the control socket of the ssh master process that
+// forwards the local port, it is used to stop the tunnel on remove.
+func (d *Driver) tunnelControlPath(localPort int) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("qingcloud-%s-%d.sock", stringValue(d.InstanceID), localPort))
+}
+
+// bastionArgs returns the ssh arguments to connect to the bastion.
+func (d *Driver) bastionArgs(b *bastion) []string {
+	args := []string{
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=quiet",
+		"-o", "ConnectTimeout=10",
+		"-p", strconv.Itoa(b.Port),
+	}
+	if d.SSHBastionKeyPath != "" {
+		args = append(args, "-o", "IdentitiesOnly=yes", "-i", d.SSHBastionKeyPath)
+	}
+	return args
+}
+
+// ensureTunnel forwards localPort to remotePort of the instance through the
+// bastion, unless the tunnel is already up. The ssh process runs in the
+// background and outlives the driver, so docker-machine ssh and the docker
+// client can use it. The tunnel is checked through the control socket of the
+// ssh process, as another process may listen on the local port.
+func (d *Driver) ensureTunnel(localPort, remotePort int) error {
+	b, err := parseBastion(d.SSHBastion)
+	if err != nil {
+		return err
+	}
+	sshBinary, err := exec.LookPath("ssh")
+	if err != nil {
+		return fmt.Errorf("SSH through bastion [%s] requires the ssh binary: %s", b, err.Error())
+	}
+	if d.tunnelUp(sshBinary, b, localPort) {
+		return nil
+	}
+	if d.IPAddress == "" {
+		return fmt.Errorf("IP address of Instance [%s] is not set.", stringValue(d.InstanceID))
+	}
+	if listening(localPort) {
+		return fmt.Errorf("Local port [%d] of the tunnel through bastion [%s] is used by another process.", localPort, b)
+	}
+	args := append(d.bastionArgs(b),
+		"-f", "-N", "-M",
+		"-S", d.tunnelControlPath(localPort),
+		"-o", "ExitOnForwardFailure=yes",
+		"-L", fmt.Sprintf("%s:%d:%s:%d", localhost, localPort, d.IPAddress, remotePort),
+		fmt.Sprintf("%s@%s", b.User, b.Host))
+	log.Debugf("Start tunnel: %s %s", sshBinary, strings.Join(args, " "))
+	if output, err := exec.Command(sshBinary, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Start tunnel [%s:%d] -> [%s:%d] through bastion [%s] error: [%s] %s",
+			localhost, localPort, d.IPAddress, remotePort, b, err.Error(), strings.TrimSpace(string(output)))
+	}
+	return mcnutils.WaitForSpecific(func() bool { return d.tunnelUp(sshBinary, b, localPort) && listening(localPort) }, 10, time.Second)
+}
+
+// closeTunnels stops the tunnels started by ensureTunnel.
+func (d *Driver) closeTunnels() {
+	b, err := parseBastion(d.SSHBastion)
+	if err != nil {
+		return
+	}
+	for _, port := range []int{d.SSHForwardPort, d.DockerForwardPort} {
+		if port == 0 {
+			continue
+		}
+		controlPath := d.tunnelControlPath(port)
+		if _, err := os.Stat(controlPath); err != nil {
+			continue
+		}
+		args := append(d.bastionArgs(b), "-S", controlPath, "-O", "exit", fmt.Sprintf("%s@%s", b.User, b.Host))
+		if err := exec.Command("ssh", args...).Run(); err != nil {
+			log.Warnf("Stop tunnel on local port [%d] fail, err: [%s]", port, err.Error())
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package qingcloud
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestParseBastion(t *testing.T) {
+	cases := map[string]string{
+		"ubuntu@jump.example.com":      "ubuntu@jump.example.com:22",
+		"ubuntu@jump.example.com:2222": "ubuntu@jump.example.com:2222",
+		"139.198.1.1:2222":             "root@139.198.1.1:2222",
+		"139.198.1.1":                  "root@139.198.1.1:22",
+	}
+	for s, expect := range cases {
+		b, err := parseBastion(s)
+		if err != nil {
+			t.Errorf("parse [%s] error: %s", s, err.Error())
+			continue
+		}
+		if b.String() != expect {
+			t.Errorf("parse [%s] expect [%s], got [%s]", s, expect, b.String())
+		}
+	}
+	for _, s := range []string{"", "ubuntu@", "@jump", "jump:ssh", "jump:70000"} {
+		if _, err := parseBastion(s); err == nil {
+			t.Errorf("expect error for [%s]", s)
+		}
+	}
+}
+
+func TestTunnelUpStaleControlSocket(t *testing.T) {
+	sshBinary, err := exec.LookPath("ssh")
+	if err != nil {
+		t.Skip("ssh binary not found")
+	}
+	d := NewDriver("test", "")
+	d.InstanceID = stringPtr("i-tunnel")
+	b := &bastion{User: "root", Host: "127.0.0.1", Port: 22}
+	controlPath := d.tunnelControlPath(2222)
+	os.Remove(controlPath)
+	if d.tunnelUp(sshBinary, b, 2222) {
+		t.Error("expect tunnel down without control socket")
+	}
+	if err := ioutil.WriteFile(controlPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(controlPath)
+	if d.tunnelUp(sshBinary, b, 2222) {
+		t.Error("expect tunnel down with a stale control socket")
+	}
+	if _, err := os.Stat(controlPath); !os.IsNotExist(err) {
+		t.Error("expect stale control socket removed")
+	}
+}
@@ -20,27 +20,99 @@ const (
 )
 const (
 	DefaultSecurityGroupName = "docker-machine"
+	DefaultTagName           = "docker-machine"
 )
 
+// pageLimit is the page size of list operations.
+const pageLimit = 100
+
 var DefaultInstanceClassByZone = map[string]int{"pek1": 0, "pek2": 0, "pek3a": 0, "gd1": 0, "ap1": 0, "sh1a": 1}
 
 type Client interface {
 	RunInstance(arg *RunInstanceArg) (*qcservice.Instance, error)
 	DescribeInstance(instanceID *string) (*qcservice.Instance, error)
+	DescribeInstances(instanceIDs []*string) ([]*qcservice.Instance, error)
+	ListInstances(tagged bool) ([]*qcservice.Instance, error)
 	StartInstance(instanceID *string) error
 	StopInstance(instanceID *string, force bool) error
+	StopInstanceWithin(instanceID *string, timeout int) error
 	RestartInstance(instanceID *string) error
 	TerminateInstance(instanceID *string) error
+	ResizeInstance(instanceID *string, cpu int, memory int) error
+	ResetInstance(instanceID *string, loginKeyPair *string) error
+	ModifyInstanceDescription(instanceID *string, description *string) error
+	DescribeInstanceTypes() ([]*qcservice.InstanceType, error)
 	WaitInstanceStatus(instanceID *string, status string) error
 
 	BindEIP(instanceID *string) (*qcservice.EIP, error)
 	ReleaseEIP(eipID *string) error
 	BindSecurityGroup(instanceID *string, rules []*qcservice.SecurityGroupRule) (*qcservice.SecurityGroup, error)
 	DeleteSecurityGroup(sgID *string) error
+	ListEIPs(tagged bool) ([]*qcservice.EIP, error)
+	ListSecurityGroups(tagged bool) ([]*qcservice.SecurityGroup, error)
 
 	CreateKeyPair(keyPairName *string, publicKey *string) (*string, error)
 	DescribeKeyPair(keyPairID *string) (*qcservice.KeyPair, error)
 	DeleteKeyPair(keyPairID *string) error
+	ListKeyPairs(tagged bool) ([]*qcservice.KeyPair, error)
+	AttachKeyPairs(instanceID *string, keyPairIDs []*string) error
+	DetachKeyPairs(instanceID *string, keyPairIDs []*string) error
+
+	CaptureInstance(instanceID *string, imageName *string) (*string, error)
+	DescribeImages(filter *ImageFilter) ([]*qcservice.Image, error)
+	GrantImageToUsers(imageID *string, users []*string) error
+
+	CreateSnapshots(resourceIDs []*string, snapshotName *string) ([]*string, error)
+	DescribeSnapshots(resourceID *string) ([]*qcservice.Snapshot, error)
+	ApplySnapshots(snapshotIDs []*string) error
+	CreateVolumeFromSnapshot(snapshotID *string, volumeName *string) (*string, error)
+
+	TagResources(tagName string, resourceType string, resourceIDs []*string) error
+
+	DescribeZones() ([]string, error)
+	GetQuotaLeft(resourceTypes []string) (map[string]int, error)
+
+	DescribeVxNet(vxnetID *string) (*qcservice.VxNet, error)
+
+	CreateNic(vxnetID *string, privateIP *string, nicName *string) (*string, error)
+	AttachNics(instanceID *string, nicIDs []*string) error
+	DetachNics(nicIDs []*string) error
+	DeleteNics(nicIDs []*string) error
+
+	AllocateEIP(eipName *string) (*qcservice.EIP, error)
+	ListRouters(tagged bool) ([]*qcservice.Router, error)
+	CreateRouter(routerName *string) (*string, error)
+	SetRouterEIP(routerID *string, eipID *string) error
+	DeleteRouter(routerID *string) error
+	CreateVxNet(vxnetName *string) (*string, error)
+	JoinRouter(routerID *string, vxnetID *string, ipNetwork *string) error
+	LeaveRouter(routerID *string, vxnetIDs []*string) error
+	DescribeRouterVxNets(routerID *string) ([]*qcservice.RouterVxNet, error)
+	CountVxNetInstances(vxnetID *string) (int, error)
+	DeleteVxNets(vxnetIDs []*string) error
+
+	GetDNSLabel() (string, string, error)
+	AssociateDNSAlias(prefix *string, resourceID *string) (*string, error)
+	DissociateDNSAlias(dnsAliasID *string) error
+
+	AddSecurityGroupRules(sgID *string, instanceID *string, rules []*qcservice.SecurityGroupRule) error
+
+	FindLoadBalancer(name *string) (*qcservice.LoadBalancer, error)
+	DescribeLoadBalancer(loadBalancerID *string) (*qcservice.LoadBalancer, error)
+	CreateLoadBalancer(name *string, eipIDs []*string, vxnetID *string) (*string, error)
+	UpdateLoadBalancer(loadBalancerID *string) error
+	DeleteLoadBalancer(loadBalancerID *string) error
+	DescribeLoadBalancerListeners(loadBalancerID *string) ([]*qcservice.LoadBalancerListener, error)
+	AddLoadBalancerListener(loadBalancerID *string, port int) (*string, error)
+	AddLoadBalancerBackend(listenerID *string, instanceID *string, port int) (*string, error)
+	DescribeLoadBalancerBackends(loadBalancerID *string) ([]*qcservice.LoadBalancerBackend, error)
+	DeleteLoadBalancerBackends(backendIDs []*string) error
+
+	DescribeS2SharedTarget(targetID *string) (*qcservice.S2SharedTarget, error)
+	DescribeS2Server(serverID *string) (*qcservice.S2Server, error)
+
+	GetInstanceMonitor(instanceID *string, meters []string, step string, start time.Time) ([]*MonitorMeter, error)
+	GetEIPMonitor(eipID *string, meters []string, step string, start time.Time) ([]*MonitorMeter, error)
 }
 
 func NewClient(config *config.Config, zone string) (Client, error) {
@@ -68,15 +140,61 @@ func NewClient(config *config.Config, zone string) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	imageService, err := qcService.Image(zone)
+	if err != nil {
+		return nil, err
+	}
+	tagService, err := qcService.Tag(zone)
+	if err != nil {
+		return nil, err
+	}
+	snapshotService, err := qcService.Snapshot(zone)
+	if err != nil {
+		return nil, err
+	}
+	vxnetService, err := qcService.VxNet(zone)
+	if err != nil {
+		return nil, err
+	}
+	nicService, err := qcService.Nic(zone)
+	if err != nil {
+		return nil, err
+	}
+	routerService, err := qcService.Router(zone)
+	if err != nil {
+		return nil, err
+	}
+	dnsAliasService, err := qcService.DNSAlias(zone)
+	if err != nil {
+		return nil, err
+	}
+	loadBalancerService, err := qcService.LoadBalancer(zone)
+	if err != nil {
+		return nil, err
+	}
+	sharedStorageService, err := qcService.SharedStorage(zone)
+	if err != nil {
+		return nil, err
+	}
 
 	instanceClass := DefaultInstanceClassByZone[zone]
 
 	c := &client{
+		qcService:            qcService,
 		instanceService:      instanceService,
 		jobService:           jobService,
 		keypairService:       keypairService,
 		eipService:           eipService,
 		securityGroupService: securityGroupService,
+		imageService:         imageService,
+		tagService:           tagService,
+		snapshotService:      snapshotService,
+		vxnetService:         vxnetService,
+		nicService:           nicService,
+		routerService:        routerService,
+		dnsAliasService:      dnsAliasService,
+		loadBalancerService:  loadBalancerService,
+		sharedStorageService: sharedStorageService,
 		opTimeout:            defaultOpTimeout,
 		zone:                 zone,
 		instanceClass:        &instanceClass,
@@ -85,11 +203,21 @@ func NewClient(config *config.Config, zone string) (Client, error) {
 }
 
 type client struct {
+	qcService            *qcservice.QingCloudService
 	instanceService      *qcservice.InstanceService
 	jobService           *qcservice.JobService
 	keypairService       *qcservice.KeyPairService
 	eipService           *qcservice.EIPService
 	securityGroupService *qcservice.SecurityGroupService
+	imageService         *qcservice.ImageService
+	tagService           *qcservice.TagService
+	snapshotService      *qcservice.SnapshotService
+	vxnetService         *qcservice.VxNetService
+	nicService           *qcservice.NicService
+	routerService        *qcservice.RouterService
+	dnsAliasService      *qcservice.DNSAliasService
+	loadBalancerService  *qcservice.LoadBalancerService
+	sharedStorageService *qcservice.SharedStorageService
 	opTimeout            int
 	zone                 string
 	instanceClass        *int
@@ -165,11 +293,60 @@ func (c *client) DescribeInstance(instanceID *string) (*qcservice.Instance, erro
 		return nil, err
 	}
 	if len(output.InstanceSet) == 0 {
-		return nil, fmt.Errorf("Instance with id [%s] not exist.", *instanceID)
+		return nil, &instanceNotFoundError{InstanceID: *instanceID}
 	}
 	return output.InstanceSet[0], nil
 }
 
+func (c *client) DescribeInstances(instanceIDs []*string) ([]*qcservice.Instance, error) {
+	instances := []*qcservice.Instance{}
+	for start := 0; start < len(instanceIDs); start += pageLimit {
+		end := start + pageLimit
+		if end > len(instanceIDs) {
+			end = len(instanceIDs)
+		}
+		input := &qcservice.DescribeInstancesInput{Instances: instanceIDs[start:end], Limit: intPtr(pageLimit)}
+		output, err := c.instanceService.DescribeInstances(input)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, output.InstanceSet...)
+	}
+	return instances, nil
+}
+
+// ListInstances returns the live instances of the zone, or only those with the driver tag.
+func (c *client) ListInstances(tagged bool) ([]*qcservice.Instance, error) {
+	input := &qcservice.DescribeInstancesInput{
+		Limit: intPtr(pageLimit),
+		Status: []*string{
+			stringPtr(INSTANCE_STATUS_PENDING),
+			stringPtr(INSTANCE_STATUS_RUNNING),
+			stringPtr(INSTANCE_STATUS_STOPPED),
+			stringPtr(INSTANCE_STATUS_SUSPENDED),
+		},
+	}
+	if tagged {
+		tagID, err := c.getTag(stringPtr(DefaultTagName))
+		if err != nil || tagID == nil {
+			return nil, err
+		}
+		input.Tags = []*string{tagID}
+	}
+	instances := []*qcservice.Instance{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.instanceService.DescribeInstances(input)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, output.InstanceSet...)
+		if len(output.InstanceSet) < pageLimit {
+			return instances, nil
+		}
+	}
+}
+
 func (c *client) StartInstance(instanceID *string) error {
 	input := &qcservice.StartInstancesInput{Instances: []*string{instanceID}}
 	output, err := c.instanceService.StartInstances(input)
@@ -204,6 +381,16 @@ func (c *client) StopInstance(instanceID *string, force bool) error {
 	return c.WaitInstanceStatus(instanceID, INSTANCE_STATUS_STOPPED)
 }
 
+// StopInstanceWithin stops the instance gracefully, and waits at most timeout
+// seconds for it to be stopped.
+func (c *client) StopInstanceWithin(instanceID *string, timeout int) error {
+	input := &qcservice.StopInstancesInput{Instances: []*string{instanceID}, Force: intPtr(0)}
+	if _, err := c.instanceService.StopInstances(input); err != nil {
+		return err
+	}
+	return c.waitInstanceStatus(instanceID, INSTANCE_STATUS_STOPPED, timeout)
+}
+
 func (c *client) RestartInstance(instanceID *string) error {
 	input := &qcservice.RestartInstancesInput{Instances: []*string{instanceID}}
 	output, err := c.instanceService.RestartInstances(input)
@@ -232,6 +419,51 @@ func (c *client) TerminateInstance(instanceID *string) error {
 	return c.WaitInstanceStatus(instanceID, INSTANCE_STATUS_TERMINATED)
 }
 
+func (c *client) ResizeInstance(instanceID *string, cpu int, memory int) error {
+	input := &qcservice.ResizeInstancesInput{Instances: []*string{instanceID}, CPU: &cpu, Memory: &memory}
+	output, err := c.instanceService.ResizeInstances(input)
+	if err != nil {
+		return err
+	}
+	jobID := output.JobID
+	return c.waitJob(jobID)
+}
+
+func (c *client) ResetInstance(instanceID *string, loginKeyPair *string) error {
+	input := &qcservice.ResetInstancesInput{Instances: []*string{instanceID}, LoginKeyPair: loginKeyPair, LoginMode: stringPtr("keypair")}
+	output, err := c.instanceService.ResetInstances(input)
+	if err != nil {
+		return err
+	}
+	jobID := output.JobID
+	return c.waitJob(jobID)
+}
+
+// ModifyInstanceDescription replaces the description of the instance.
+func (c *client) ModifyInstanceDescription(instanceID *string, description *string) error {
+	input := &qcservice.ModifyInstanceAttributesInput{Instance: instanceID, Description: description}
+	_, err := c.instanceService.ModifyInstanceAttributes(input)
+	return err
+}
+
+func (c *client) DescribeInstanceTypes() ([]*qcservice.InstanceType, error) {
+	output, err := c.instanceService.DescribeInstanceTypes(&qcservice.DescribeInstanceTypesInput{})
+	if err != nil {
+		return nil, err
+	}
+	types := []*qcservice.InstanceType{}
+	for _, t := range output.InstanceTypeSet {
+		if t.ZoneID != nil && *t.ZoneID != c.zone {
+			continue
+		}
+		if t.Status != nil && *t.Status != "available" {
+			continue
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
 func (c *client) BindEIP(instanceID *string) (*qcservice.EIP, error) {
 	eip, err := c.allocateEIP(instanceID)
 	if err != nil {
@@ -250,6 +482,11 @@ func (c *client) BindEIP(instanceID *string) (*qcservice.EIP, error) {
 	return eip, nil
 }
 
+// AllocateEIP allocates an EIP which is not associated to an instance, e.g. for a router.
+func (c *client) AllocateEIP(eipName *string) (*qcservice.EIP, error) {
+	return c.allocateEIP(eipName)
+}
+
 func (c *client) allocateEIP(instanceID *string) (*qcservice.EIP, error) {
 	allocateEIPInput := &qcservice.AllocateEIPsInput{Bandwidth: intPtr(defaultEIPBandwidth), EIPName: instanceID}
 	allocateEIPOutput, err := c.eipService.AllocateEIPs(allocateEIPInput)
@@ -318,6 +555,19 @@ func (c *client) addSecurityRule(sgID *string, rules []*qcservice.SecurityGroupR
 	return nil
 }
 
+// AddSecurityGroupRules adds rules to the security group and applies it to the instance.
+func (c *client) AddSecurityGroupRules(sgID *string, instanceID *string, rules []*qcservice.SecurityGroupRule) error {
+	if err := c.addSecurityRule(sgID, rules); err != nil {
+		return err
+	}
+	input := &qcservice.ApplySecurityGroupInput{SecurityGroup: sgID, Instances: []*string{instanceID}}
+	output, err := c.securityGroupService.ApplySecurityGroup(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
 func (c *client) DeleteSecurityGroup(sgID *string) error {
 	input := &qcservice.DeleteSecurityGroupsInput{SecurityGroups: []*string{sgID}}
 	_, err := c.securityGroupService.DeleteSecurityGroups(input)
@@ -327,6 +577,54 @@ func (c *client) DeleteSecurityGroup(sgID *string) error {
 	return nil
 }
 
+// ListEIPs returns all EIPs of the zone, or only those with the driver tag.
+func (c *client) ListEIPs(tagged bool) ([]*qcservice.EIP, error) {
+	input := &qcservice.DescribeEIPsInput{Limit: intPtr(pageLimit)}
+	if tagged {
+		tagID, err := c.getTag(stringPtr(DefaultTagName))
+		if err != nil || tagID == nil {
+			return nil, err
+		}
+		input.Tags = []*string{tagID}
+	}
+	eips := []*qcservice.EIP{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.eipService.DescribeEIPs(input)
+		if err != nil {
+			return nil, err
+		}
+		eips = append(eips, output.EIPSet...)
+		if len(output.EIPSet) < pageLimit {
+			return eips, nil
+		}
+	}
+}
+
+// ListSecurityGroups returns all security groups of the zone, or only those with the driver tag.
+func (c *client) ListSecurityGroups(tagged bool) ([]*qcservice.SecurityGroup, error) {
+	input := &qcservice.DescribeSecurityGroupsInput{Limit: intPtr(pageLimit), Verbose: intPtr(1)}
+	if tagged {
+		tagID, err := c.getTag(stringPtr(DefaultTagName))
+		if err != nil || tagID == nil {
+			return nil, err
+		}
+		input.Tags = []*string{tagID}
+	}
+	sgs := []*qcservice.SecurityGroup{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.securityGroupService.DescribeSecurityGroups(input)
+		if err != nil {
+			return nil, err
+		}
+		sgs = append(sgs, output.SecurityGroupSet...)
+		if len(output.SecurityGroupSet) < pageLimit {
+			return sgs, nil
+		}
+	}
+}
+
 func (c *client) CreateKeyPair(keyPairName *string, publicKey *string) (*string, error) {
 	log.Debugf("Create KeyPair name: [%s], publicKey: [%s]", *keyPairName, *publicKey)
 	input := &qcservice.CreateKeyPairInput{Mode: stringPtr("user"), KeyPairName: keyPairName, PublicKey: publicKey}
@@ -347,11 +645,53 @@ func (c *client) DescribeKeyPair(keyPairID *string) (*qcservice.KeyPair, error)
 		return nil, err
 	}
 	if len(output.KeyPairSet) == 0 {
-		return nil, fmt.Errorf("KeyPair with id [%s] not exist.", keyPairID)
+		return nil, &resourceNotFoundError{Type: "KeyPair", ID: *keyPairID}
 	}
 	return output.KeyPairSet[0], nil
 }
 
+// ListKeyPairs returns all keypairs of the zone, or only those with the driver tag.
+func (c *client) ListKeyPairs(tagged bool) ([]*qcservice.KeyPair, error) {
+	input := &qcservice.DescribeKeyPairsInput{Limit: intPtr(pageLimit)}
+	if tagged {
+		tagID, err := c.getTag(stringPtr(DefaultTagName))
+		if err != nil || tagID == nil {
+			return nil, err
+		}
+		input.Tags = []*string{tagID}
+	}
+	keyPairs := []*qcservice.KeyPair{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.keypairService.DescribeKeyPairs(input)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, output.KeyPairSet...)
+		if len(output.KeyPairSet) < pageLimit {
+			return keyPairs, nil
+		}
+	}
+}
+
+func (c *client) AttachKeyPairs(instanceID *string, keyPairIDs []*string) error {
+	input := &qcservice.AttachKeyPairsInput{Instances: []*string{instanceID}, KeyPairs: keyPairIDs}
+	output, err := c.keypairService.AttachKeyPairs(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DetachKeyPairs(instanceID *string, keyPairIDs []*string) error {
+	input := &qcservice.DetachKeyPairsInput{Instances: []*string{instanceID}, KeyPairs: keyPairIDs}
+	output, err := c.keypairService.DetachKeyPairs(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
 func (c *client) DeleteKeyPair(keyPairID *string) error {
 	input := &qcservice.DeleteKeyPairsInput{KeyPairs: []*string{keyPairID}}
 	_, err := c.keypairService.DeleteKeyPairs(input)
@@ -361,6 +701,543 @@ func (c *client) DeleteKeyPair(keyPairID *string) error {
 	return nil
 }
 
+func (c *client) CaptureInstance(instanceID *string, imageName *string) (*string, error) {
+	input := &qcservice.CaptureInstanceInput{Instance: instanceID, ImageName: imageName}
+	output, err := c.imageService.CaptureInstance(input)
+	if err != nil {
+		return nil, err
+	}
+	jobID := output.JobID
+	err = c.waitJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return output.ImageID, nil
+}
+
+type ImageFilter struct {
+	ImageIDs      []string
+	Provider      string
+	Visibility    string
+	OSFamily      string
+	ProcessorType string
+}
+
+func (c *client) DescribeImages(filter *ImageFilter) ([]*qcservice.Image, error) {
+	input := &qcservice.DescribeImagesInput{Status: []*string{stringPtr("available")}, Limit: intPtr(pageLimit)}
+	for _, id := range filter.ImageIDs {
+		input.Images = append(input.Images, stringPtr(id))
+	}
+	if filter.Provider != "" {
+		input.Provider = &filter.Provider
+	}
+	if filter.Visibility != "" {
+		input.Visibility = &filter.Visibility
+	}
+	if filter.OSFamily != "" {
+		input.OSFamily = &filter.OSFamily
+	}
+	if filter.ProcessorType != "" {
+		input.ProcessorType = &filter.ProcessorType
+	}
+	images := []*qcservice.Image{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.imageService.DescribeImages(input)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, output.ImageSet...)
+		if len(output.ImageSet) < pageLimit || (output.TotalCount != nil && len(images) >= *output.TotalCount) {
+			return images, nil
+		}
+	}
+}
+
+func (c *client) GrantImageToUsers(imageID *string, users []*string) error {
+	input := &qcservice.GrantImageToUsersInput{Image: imageID, Users: users}
+	_, err := c.imageService.GrantImageToUsers(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *client) CreateSnapshots(resourceIDs []*string, snapshotName *string) ([]*string, error) {
+	input := &qcservice.CreateSnapshotsInput{Resources: resourceIDs, SnapshotName: snapshotName}
+	output, err := c.snapshotService.CreateSnapshots(input)
+	if err != nil {
+		return nil, err
+	}
+	jobID := output.JobID
+	err = c.waitJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return output.Snapshots, nil
+}
+
+func (c *client) DescribeSnapshots(resourceID *string) ([]*qcservice.Snapshot, error) {
+	input := &qcservice.DescribeSnapshotsInput{ResourceID: resourceID, Status: []*string{stringPtr("available")}, Limit: intPtr(pageLimit)}
+	snapshots := []*qcservice.Snapshot{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.snapshotService.DescribeSnapshots(input)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, output.SnapshotSet...)
+		if len(output.SnapshotSet) < pageLimit {
+			return snapshots, nil
+		}
+	}
+}
+
+func (c *client) ApplySnapshots(snapshotIDs []*string) error {
+	input := &qcservice.ApplySnapshotsInput{Snapshots: snapshotIDs}
+	output, err := c.snapshotService.ApplySnapshots(input)
+	if err != nil {
+		return err
+	}
+	jobID := output.JobID
+	return c.waitJob(jobID)
+}
+
+func (c *client) CreateVolumeFromSnapshot(snapshotID *string, volumeName *string) (*string, error) {
+	input := &qcservice.CreateVolumeFromSnapshotInput{Snapshot: snapshotID, VolumeName: volumeName}
+	output, err := c.snapshotService.CreateVolumeFromSnapshot(input)
+	if err != nil {
+		return nil, err
+	}
+	jobID := output.JobID
+	err = c.waitJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return output.VolumeID, nil
+}
+
+// TagResources attaches the named tag to the resources, creating the tag on first use.
+func (c *client) TagResources(tagName string, resourceType string, resourceIDs []*string) error {
+	tagID, err := c.getOrCreateTag(&tagName)
+	if err != nil {
+		return err
+	}
+	pairs := []*qcservice.ResourceTagPair{}
+	for _, resourceID := range resourceIDs {
+		pairs = append(pairs, &qcservice.ResourceTagPair{TagID: tagID, ResourceID: resourceID, ResourceType: &resourceType})
+	}
+	input := &qcservice.AttachTagsInput{ResourceTagPairs: pairs}
+	_, err = c.tagService.AttachTags(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// getTag returns the ID of the named tag, or nil if the tag does not exist.
+func (c *client) getTag(tagName *string) (*string, error) {
+	input := &qcservice.DescribeTagsInput{SearchWord: tagName}
+	output, err := c.tagService.DescribeTags(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range output.TagSet {
+		if tag.TagName != nil && *tag.TagName == *tagName {
+			return tag.TagID, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *client) getOrCreateTag(tagName *string) (*string, error) {
+	tagID, err := c.getTag(tagName)
+	if err != nil || tagID != nil {
+		return tagID, err
+	}
+	createOutput, err := c.tagService.CreateTag(&qcservice.CreateTagInput{TagName: tagName})
+	if err != nil {
+		return nil, err
+	}
+	return createOutput.TagID, nil
+}
+
+func (c *client) DescribeVxNet(vxnetID *string) (*qcservice.VxNet, error) {
+	input := &qcservice.DescribeVxNetsInput{VxNets: []*string{vxnetID}}
+	output, err := c.vxnetService.DescribeVxNets(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.VxNetSet) == 0 {
+		return nil, fmt.Errorf("VxNet with id [%s] not exist.", *vxnetID)
+	}
+	return output.VxNetSet[0], nil
+}
+
+// CreateNic creates a NIC in the vxnet, privateIP is optional.
+func (c *client) CreateNic(vxnetID *string, privateIP *string, nicName *string) (*string, error) {
+	input := &qcservice.CreateNicsInput{VxNet: vxnetID, NICName: nicName, Count: intPtr(1)}
+	if privateIP != nil && *privateIP != "" {
+		input.PrivateIPs = []*string{privateIP}
+	}
+	output, err := c.nicService.CreateNics(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Nics) == 0 {
+		return nil, fmt.Errorf("Create NIC in VxNet [%s] return no NIC.", *vxnetID)
+	}
+	return output.Nics[0].NICID, nil
+}
+
+func (c *client) AttachNics(instanceID *string, nicIDs []*string) error {
+	input := &qcservice.AttachNicsInput{Instance: instanceID, Nics: nicIDs}
+	output, err := c.nicService.AttachNics(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DetachNics(nicIDs []*string) error {
+	input := &qcservice.DetachNicsInput{Nics: nicIDs}
+	output, err := c.nicService.DetachNics(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DeleteNics(nicIDs []*string) error {
+	input := &qcservice.DeleteNicsInput{Nics: nicIDs}
+	_, err := c.nicService.DeleteNics(input)
+	return err
+}
+
+// ListRouters returns the routers of the zone, or only those with the driver tag.
+func (c *client) ListRouters(tagged bool) ([]*qcservice.Router, error) {
+	input := &qcservice.DescribeRoutersInput{
+		Limit:  intPtr(pageLimit),
+		Status: []*string{stringPtr("pending"), stringPtr("active"), stringPtr("poweroffed")},
+	}
+	if tagged {
+		tagID, err := c.getTag(stringPtr(DefaultTagName))
+		if err != nil || tagID == nil {
+			return nil, err
+		}
+		input.Tags = []*string{tagID}
+	}
+	routers := []*qcservice.Router{}
+	for offset := 0; ; offset += pageLimit {
+		input.Offset = intPtr(offset)
+		output, err := c.routerService.DescribeRouters(input)
+		if err != nil {
+			return nil, err
+		}
+		routers = append(routers, output.RouterSet...)
+		if len(output.RouterSet) < pageLimit {
+			return routers, nil
+		}
+	}
+}
+
+func (c *client) CreateRouter(routerName *string) (*string, error) {
+	input := &qcservice.CreateRoutersInput{RouterName: routerName, Count: intPtr(1)}
+	output, err := c.routerService.CreateRouters(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Routers) == 0 {
+		return nil, fmt.Errorf("Create Router [%s] return no router.", *routerName)
+	}
+	if err := c.waitJob(output.JobID); err != nil {
+		return nil, err
+	}
+	return output.Routers[0], nil
+}
+
+// SetRouterEIP binds the EIP to the router and applies the change.
+func (c *client) SetRouterEIP(routerID *string, eipID *string) error {
+	input := &qcservice.ModifyRouterAttributesInput{Router: routerID, EIP: eipID}
+	if _, err := c.routerService.ModifyRouterAttributes(input); err != nil {
+		return err
+	}
+	output, err := c.routerService.UpdateRouters(&qcservice.UpdateRoutersInput{Routers: []*string{routerID}})
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DeleteRouter(routerID *string) error {
+	input := &qcservice.DeleteRoutersInput{Routers: []*string{routerID}}
+	output, err := c.routerService.DeleteRouters(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+// CreateVxNet creates a managed vxnet, which can join a router.
+func (c *client) CreateVxNet(vxnetName *string) (*string, error) {
+	input := &qcservice.CreateVxNetsInput{VxNetName: vxnetName, VxNetType: intPtr(1), Count: intPtr(1)}
+	output, err := c.vxnetService.CreateVxNets(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.VxNets) == 0 {
+		return nil, fmt.Errorf("Create VxNet [%s] return no vxnet.", *vxnetName)
+	}
+	return output.VxNets[0], nil
+}
+
+func (c *client) JoinRouter(routerID *string, vxnetID *string, ipNetwork *string) error {
+	input := &qcservice.JoinRouterInput{Router: routerID, VxNet: vxnetID, IPNetwork: ipNetwork}
+	output, err := c.routerService.JoinRouter(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) LeaveRouter(routerID *string, vxnetIDs []*string) error {
+	input := &qcservice.LeaveRouterInput{Router: routerID, VxNets: vxnetIDs}
+	output, err := c.routerService.LeaveRouter(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DescribeRouterVxNets(routerID *string) ([]*qcservice.RouterVxNet, error) {
+	input := &qcservice.DescribeRouterVxNetsInput{Router: routerID, Limit: intPtr(pageLimit)}
+	output, err := c.routerService.DescribeRouterVxNets(input)
+	if err != nil {
+		return nil, err
+	}
+	return output.RouterVxNetSet, nil
+}
+
+// CountVxNetInstances returns the number of instances in the vxnet.
+func (c *client) CountVxNetInstances(vxnetID *string) (int, error) {
+	input := &qcservice.DescribeVxNetInstancesInput{VxNet: vxnetID, Limit: intPtr(1)}
+	output, err := c.vxnetService.DescribeVxNetInstances(input)
+	if err != nil {
+		return 0, err
+	}
+	if output.TotalCount == nil {
+		return len(output.InstanceSet), nil
+	}
+	return *output.TotalCount, nil
+}
+
+func (c *client) DeleteVxNets(vxnetIDs []*string) error {
+	input := &qcservice.DeleteVxNetsInput{VxNets: vxnetIDs}
+	_, err := c.vxnetService.DeleteVxNets(input)
+	return err
+}
+
+// GetDNSLabel returns the dns label and domain name of the account, aliases
+// are named prefix.label.domain.
+func (c *client) GetDNSLabel() (string, string, error) {
+	output, err := c.dnsAliasService.GetDNSLabel(&qcservice.GetDNSLabelInput{})
+	if err != nil {
+		return "", "", err
+	}
+	return stringValue(output.DNSLabel), stringValue(output.DomainName), nil
+}
+
+func (c *client) AssociateDNSAlias(prefix *string, resourceID *string) (*string, error) {
+	input := &qcservice.AssociateDNSAliasInput{Prefix: prefix, Resource: resourceID}
+	output, err := c.dnsAliasService.AssociateDNSAlias(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitJob(output.JobID); err != nil {
+		return nil, err
+	}
+	return output.DNSAliasID, nil
+}
+
+func (c *client) DissociateDNSAlias(dnsAliasID *string) error {
+	input := &qcservice.DissociateDNSAliasesInput{DNSAliases: []*string{dnsAliasID}}
+	output, err := c.dnsAliasService.DissociateDNSAliases(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+// FindLoadBalancer returns the load balancer with the name, or nil if there is none.
+func (c *client) FindLoadBalancer(name *string) (*qcservice.LoadBalancer, error) {
+	input := &qcservice.DescribeLoadBalancersInput{
+		SearchWord: name,
+		Status:     []*string{stringPtr("pending"), stringPtr("active"), stringPtr("stopped")},
+		Limit:      intPtr(pageLimit),
+	}
+	output, err := c.loadBalancerService.DescribeLoadBalancers(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, lb := range output.LoadBalancerSet {
+		if lb.LoadBalancerName != nil && *lb.LoadBalancerName == *name {
+			return lb, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *client) DescribeLoadBalancer(loadBalancerID *string) (*qcservice.LoadBalancer, error) {
+	input := &qcservice.DescribeLoadBalancersInput{LoadBalancers: []*string{loadBalancerID}}
+	output, err := c.loadBalancerService.DescribeLoadBalancers(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.LoadBalancerSet) == 0 {
+		return nil, &resourceNotFoundError{Type: "LoadBalancer", ID: *loadBalancerID}
+	}
+	return output.LoadBalancerSet[0], nil
+}
+
+// CreateLoadBalancer creates a load balancer with the EIPs in the base
+// network, or in the vxnet if eipIDs is empty.
+func (c *client) CreateLoadBalancer(name *string, eipIDs []*string, vxnetID *string) (*string, error) {
+	input := &qcservice.CreateLoadBalancerInput{LoadBalancerName: name}
+	if len(eipIDs) > 0 {
+		input.EIPs = eipIDs
+	} else {
+		input.VxNet = vxnetID
+	}
+	output, err := c.loadBalancerService.CreateLoadBalancer(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitJob(output.JobID); err != nil {
+		return nil, err
+	}
+	return output.LoadBalancerID, nil
+}
+
+// UpdateLoadBalancer applies the listener and backend changes.
+func (c *client) UpdateLoadBalancer(loadBalancerID *string) error {
+	input := &qcservice.UpdateLoadBalancersInput{LoadBalancers: []*string{loadBalancerID}}
+	output, err := c.loadBalancerService.UpdateLoadBalancers(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DeleteLoadBalancer(loadBalancerID *string) error {
+	input := &qcservice.DeleteLoadBalancersInput{LoadBalancers: []*string{loadBalancerID}}
+	output, err := c.loadBalancerService.DeleteLoadBalancers(input)
+	if err != nil {
+		return err
+	}
+	return c.waitJob(output.JobID)
+}
+
+func (c *client) DescribeLoadBalancerListeners(loadBalancerID *string) ([]*qcservice.LoadBalancerListener, error) {
+	input := &qcservice.DescribeLoadBalancerListenersInput{LoadBalancer: loadBalancerID, Limit: intPtr(pageLimit)}
+	output, err := c.loadBalancerService.DescribeLoadBalancerListeners(input)
+	if err != nil {
+		return nil, err
+	}
+	return output.LoadBalancerListenerSet, nil
+}
+
+// AddLoadBalancerListener adds a tcp listener on the port.
+func (c *client) AddLoadBalancerListener(loadBalancerID *string, port int) (*string, error) {
+	listener := &qcservice.LoadBalancerListener{
+		ListenerPort:     intPtr(port),
+		ListenerProtocol: stringPtr("tcp"),
+		BackendProtocol:  stringPtr("tcp"),
+		BalanceMode:      stringPtr("roundrobin"),
+	}
+	input := &qcservice.AddLoadBalancerListenersInput{LoadBalancer: loadBalancerID, Listeners: []*qcservice.LoadBalancerListener{listener}}
+	output, err := c.loadBalancerService.AddLoadBalancerListeners(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.LoadBalancerListeners) == 0 {
+		return nil, fmt.Errorf("Add listener on port [%d] to LoadBalancer [%s] return no listener.", port, *loadBalancerID)
+	}
+	return output.LoadBalancerListeners[0], nil
+}
+
+func (c *client) AddLoadBalancerBackend(listenerID *string, instanceID *string, port int) (*string, error) {
+	backend := &qcservice.LoadBalancerBackend{
+		LoadBalancerBackendName: instanceID,
+		ResourceID:              instanceID,
+		Port:                    intPtr(port),
+		Weight:                  intPtr(1),
+	}
+	input := &qcservice.AddLoadBalancerBackendsInput{LoadBalancerListener: listenerID, Backends: []*qcservice.LoadBalancerBackend{backend}}
+	output, err := c.loadBalancerService.AddLoadBalancerBackends(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.LoadBalancerBackends) == 0 {
+		return nil, fmt.Errorf("Add backend [%s] to listener [%s] return no backend.", *instanceID, *listenerID)
+	}
+	return output.LoadBalancerBackends[0], nil
+}
+
+func (c *client) DescribeLoadBalancerBackends(loadBalancerID *string) ([]*qcservice.LoadBalancerBackend, error) {
+	input := &qcservice.DescribeLoadBalancerBackendsInput{LoadBalancer: loadBalancerID, Limit: intPtr(pageLimit)}
+	output, err := c.loadBalancerService.DescribeLoadBalancerBackends(input)
+	if err != nil {
+		return nil, err
+	}
+	return output.LoadBalancerBackendSet, nil
+}
+
+func (c *client) DeleteLoadBalancerBackends(backendIDs []*string) error {
+	input := &qcservice.DeleteLoadBalancerBackendsInput{LoadBalancerBackends: backendIDs}
+	_, err := c.loadBalancerService.DeleteLoadBalancerBackends(input)
+	return err
+}
+
+func (c *client) DescribeS2SharedTarget(targetID *string) (*qcservice.S2SharedTarget, error) {
+	input := &qcservice.DescribeS2SharedTargetsInput{SharedTargets: []*string{targetID}}
+	output, err := c.sharedStorageService.DescribeS2SharedTargets(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.SharedTargetSet) == 0 {
+		return nil, fmt.Errorf("S2 shared target with id [%s] not exist.", *targetID)
+	}
+	return output.SharedTargetSet[0], nil
+}
+
+func (c *client) DescribeS2Server(serverID *string) (*qcservice.S2Server, error) {
+	input := &qcservice.DescribeS2ServersInput{S2Servers: []*string{serverID}}
+	output, err := c.sharedStorageService.DescribeS2Servers(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.S2ServerSet) == 0 {
+		return nil, fmt.Errorf("S2 server with id [%s] not exist.", *serverID)
+	}
+	return output.S2ServerSet[0], nil
+}
+
+// DescribeZones returns IDs of the active zones of the account.
+func (c *client) DescribeZones() ([]string, error) {
+	input := &qcservice.DescribeZonesInput{Status: []*string{stringPtr("active")}}
+	output, err := c.qcService.DescribeZones(input)
+	if err != nil {
+		return nil, err
+	}
+	zones := []string{}
+	for _, zone := range output.ZoneSet {
+		if zone.ZoneID != nil {
+			zones = append(zones, *zone.ZoneID)
+		}
+	}
+	return zones, nil
+}
+
 func (c *client) waitJob(jobID *string) error {
 	log.Debugf("Waiting for Job [%s] finished", *jobID)
 	return mcnutils.WaitForSpecificOrError(func() (bool, error) {
@@ -392,14 +1269,20 @@ func (c *client) waitJob(jobID *string) error {
 }
 
 func (c *client) WaitInstanceStatus(instanceID *string, status string) error {
+	return c.waitInstanceStatus(instanceID, status, c.opTimeout)
+}
+
+func (c *client) waitInstanceStatus(instanceID *string, status string, timeout int) error {
 	log.Debugf("Waiting for Instance [%s] status [%s] ", *instanceID, status)
 	errorTimes := 0
-	return mcnutils.WaitForSpecificOrError(func() (bool, error) {
+	var describeErr error
+	err := mcnutils.WaitForSpecificOrError(func() (bool, error) {
 		i, err := c.DescribeInstance(instanceID)
 		if err != nil {
 			log.Errorf("DescribeInstance [%s] error : [%s]", *instanceID, err.Error())
 			errorTimes += 1
 			if errorTimes > 3 {
+				describeErr = err
 				return false, err
 			} else {
 				return false, nil
@@ -414,7 +1297,12 @@ func (c *client) WaitInstanceStatus(instanceID *string, status string) error {
 			return true, nil
 		}
 		return false, nil
-	}, (c.opTimeout / 5), 5*time.Second)
+	}, (timeout / 5), 5*time.Second)
+	if err != nil && describeErr == nil {
+		// the retries are exhausted
+		return &waitTimeoutError{InstanceID: *instanceID, Status: status, Timeout: timeout}
+	}
+	return err
 }
 
 func (c *client) waitInstanceNetwork(instanceID *string) (*qcservice.Instance, error) {
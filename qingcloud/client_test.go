@@ -79,7 +79,7 @@ func TestClient(t *testing.T) {
 	if *i2.Status != "running" {
 		t.Error("expect status running, but get ", i2.Status)
 	}
-	fmt.Printf("stoping instance: %s\n", instanceID)
+	fmt.Printf("stoping instance: %s\n", *instanceID)
 	stopErr := client.StopInstance(instanceID, false)
 	if stopErr != nil {
 		t.Fatal(stopErr)
@@ -92,7 +92,7 @@ func TestClient(t *testing.T) {
 	if *i3.Status != "stopped" {
 		t.Error("expect status stopped, but get ", i3.Status)
 	}
-	fmt.Printf("starting instance: %s \n", instanceID)
+	fmt.Printf("starting instance: %s \n", *instanceID)
 	startErr := client.StartInstance(instanceID)
 	if startErr != nil {
 		t.Fatal(startErr)
@@ -111,7 +111,7 @@ func TestClient(t *testing.T) {
 		t.Fatal(restartErr)
 	}
 
-	fmt.Printf("terminate instance: %s\n", instanceID)
+	fmt.Printf("terminate instance: %s\n", *instanceID)
 	delErr := client.TerminateInstance(instanceID)
 	if delErr != nil {
 		t.Fatal(delErr)
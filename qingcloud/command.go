@@ -0,0 +1,207 @@
+package qingcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// Command is a helper operation exposed by the plugin binary, e.g.
+// "docker-machine-driver-qingcloud capture <machine-name>".
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = map[string]*Command{}
+
+func init() {
+	// registered in init to break the initialization loop through newFlagSet
+	for _, cmd := range []*Command{
+		{
+			Name:  "capture",
+			Usage: "capture [options] <machine-name>\n\tStop the machine and capture it as a reusable image.",
+			Run:   runCapture,
+		},
+		{
+			Name:  "resize",
+			Usage: "resize --cpu <count> --memory <MB> <machine-name>\n\tResize cpu and memory of the machine, a running machine is restarted.",
+			Run:   runResize,
+		},
+		{
+			Name:  "backup",
+			Usage: "backup <machine-name>\n\tSnapshot the root disk and attached volumes of the machine.",
+			Run:   runBackup,
+		},
+		{
+			Name:  "restore",
+			Usage: "restore [options] <machine-name>\n\tApply snapshots to the machine, or create new volumes from them.",
+			Run:   runRestore,
+		},
+		{
+			Name:  "reset",
+			Usage: "reset <machine-name>\n\tReinstall the image on the machine, keeping its IP, EIP, security group and keypair.",
+			Run:   runReset,
+		},
+		{
+			Name:  "rotate-key",
+			Usage: "rotate-key <machine-name>\n\tReplace the login keypair of the machine with a newly generated key, keeping the instance.",
+			Run:   runRotateKey,
+		},
+		{
+			Name:  "gc",
+			Usage: "gc [options]\n\tFind EIPs, security groups and keypairs left behind by terminated machines, delete them with --yes.",
+			Run:   runGC,
+		},
+		{
+			Name:  "inventory",
+			Usage: "inventory [options]\n\tList instances created by this driver in every zone, and the machines they are registered as.",
+			Run:   runInventory,
+		},
+		{
+			Name:  "metrics",
+			Usage: "metrics [options]\n\tServe cpu, memory, disk, network and EIP bandwidth of instances created by this driver as a prometheus endpoint.",
+			Run:   runMetrics,
+		},
+		{
+			Name:  "reap",
+			Usage: "reap [options]\n\tFind running instances created by this driver which were idle longer than their ttl, stop or snapshot and terminate them with --yes.",
+			Run:   runReap,
+		},
+		{
+			Name:  "expire",
+			Usage: "expire [options]\n\tFind instances created by this driver whose qingcloud-ttl lease has expired, terminate them and release their resources with --yes.",
+			Run:   runExpire,
+		},
+		{
+			Name:  "extend",
+			Usage: "extend [--ttl <duration>] <machine-name>\n\tExtend the lease of the machine to now + ttl, default is its qingcloud-ttl.",
+			Run:   runExtend,
+		},
+	} {
+		commands[cmd.Name] = cmd
+	}
+}
+
+// IsCommand returns true if name is a helper command of the plugin binary.
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// RunCommand runs the helper command named by args[0] with the remaining args.
+func RunCommand(args []string) error {
+	if len(args) == 0 {
+		printCommandUsage()
+		return errors.New("Command required.")
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printCommandUsage()
+		return fmt.Errorf("Unknown command [%s].", args[0])
+	}
+	return cmd.Run(args[1:])
+}
+
+func printCommandUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].Usage)
+	}
+}
+
+// newFlagSet returns a flag set for the named command with the options shared
+// by all commands.
+func newFlagSet(name string) (*flag.FlagSet, *machineStore) {
+	store := &machineStore{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&store.path, "storage-path", defaultStoragePath(), "docker-machine storage path")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n\nOptions:\n", filepath.Base(os.Args[0]), commands[name].Usage)
+		fs.PrintDefaults()
+	}
+	return fs, store
+}
+
+// accountFlags registers the QingCloud account flags of commands which are not
+// bound to a machine, returns a driver configured by them after parse.
+func accountFlags(fs *flag.FlagSet) *Driver {
+	d := NewDriver("", "")
+	fs.StringVar(&d.AccessKeyID, "access-key-id", os.Getenv("QINGCLOUD_ACCESS_KEY_ID"), "QingCloud access key id")
+	fs.StringVar(&d.SecretAccessKey, "secret-access-key", os.Getenv("QINGCLOUD_SECRET_ACCESS_KEY"), "QingCloud secret access key")
+	zone := os.Getenv("QINGCLOUD_ZONE")
+	if zone == "" {
+		zone = defaultZone
+	}
+	fs.StringVar(&d.Zone, "zone", zone, "QingCloud zone")
+	return d
+}
+
+// zonesFlag registers --all-zones, the returned func lists the zones to work on
+// once the flags are parsed: every zone returned by DescribeZones, or only --zone.
+func zonesFlag(fs *flag.FlagSet, d *Driver, verb string) func() ([]string, error) {
+	allZones := fs.Bool("all-zones", true, verb+" instances of every zone returned by DescribeZones, otherwise only --zone")
+	return func() ([]string, error) {
+		if !*allZones {
+			return []string{d.Zone}, nil
+		}
+		return d.GetClient().DescribeZones()
+	}
+}
+
+func defaultStoragePath() string {
+	if path := os.Getenv("MACHINE_STORAGE_PATH"); path != "" {
+		return path
+	}
+	return filepath.Join(mcnutils.GetHomeDir(), ".docker", "machine")
+}
+
+// machineStore loads and saves qingcloud machines of a docker-machine store.
+type machineStore struct {
+	path string
+}
+
+func (s *machineStore) filestore() *persist.Filestore {
+	certsDir := filepath.Join(s.path, "certs")
+	return persist.NewFilestore(s.path, filepath.Join(certsDir, "ca.pem"), filepath.Join(certsDir, "ca-key.pem"))
+}
+
+// Load returns the host and the qingcloud driver of the named machine.
+func (s *machineStore) Load(name string) (*host.Host, *Driver, error) {
+	h, err := s.filestore().Load(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.DriverName != "qingcloud" {
+		return nil, nil, fmt.Errorf("Machine [%s] is not created by qingcloud driver, driver: [%s]", name, h.DriverName)
+	}
+	d := NewDriver(name, s.path)
+	if err := json.Unmarshal(h.RawDriver, d); err != nil {
+		return nil, nil, fmt.Errorf("Load driver config of machine [%s] error: [%s]", name, err.Error())
+	}
+	if d.InstanceID == nil {
+		return nil, nil, fmt.Errorf("Machine [%s] has no instance.", name)
+	}
+	h.Driver = d
+	return h, d, nil
+}
+
+// Save persists the driver config of the host back to the store.
+func (s *machineStore) Save(h *host.Host, d *Driver) error {
+	h.Driver = d
+	return s.filestore().Save(h)
+}
@@ -0,0 +1,102 @@
+package qingcloud
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testMachineConfig = `{
+    "ConfigVersion": 3,
+    "Driver": {
+        "IPAddress": "10.0.0.2",
+        "MachineName": "i-abcdefgh",
+        "SSHUser": "root",
+        "Zone": "pek3a",
+        "Image": "xenialx64b",
+        "CPU": 1,
+        "Memory": 1024,
+        "InstanceID": "i-abcdefgh"
+    },
+    "DriverName": "qingcloud",
+    "HostOptions": {},
+    "Name": "test"
+}`
+
+func TestMachineStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qingcloud-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	machineDir := filepath.Join(dir, "machines", "test")
+	if err := os.MkdirAll(machineDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(machineDir, "config.json"), []byte(testMachineConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store := &machineStore{path: dir}
+	h, d, err := store.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.InstanceID == nil || *d.InstanceID != "i-abcdefgh" {
+		t.Errorf("expect instance id i-abcdefgh, but get %v", d.InstanceID)
+	}
+	d.CPU = 2
+	if err := store.Save(h, d); err != nil {
+		t.Fatal(err)
+	}
+	_, d2, err := store.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2.CPU != 2 {
+		t.Errorf("expect cpu 2, but get %d", d2.CPU)
+	}
+	if _, _, err := store.Load("not-exist"); err == nil {
+		t.Error("expect error when load not exist machine")
+	}
+}
+
+func TestRunCommandUnknown(t *testing.T) {
+	if IsCommand("not-exist") {
+		t.Error("expect not-exist is not a command")
+	}
+	if err := RunCommand([]string{"not-exist"}); err == nil {
+		t.Error("expect error when run unknown command")
+	}
+}
+
+func TestZonesFlag(t *testing.T) {
+	c := newFakeClient()
+	c.zones = []string{"pek3a", "sh1a"}
+	cases := map[string][]string{
+		"":                  {"pek3a", "sh1a"},
+		"--all-zones=false": {"gd2"},
+	}
+	for arg, expect := range cases {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		d := newFakeDriver(c, "")
+		d.Zone = "gd2"
+		listZones := zonesFlag(fs, d, "list")
+		args := []string{}
+		if arg != "" {
+			args = append(args, arg)
+		}
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		zones, err := listZones()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(zones, expect) {
+			t.Errorf("expect zones %v with [%s], but get %v", expect, arg, zones)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package qingcloud
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var dnsPrefixPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// checkDNSAlias validates qingcloud-dns-alias.
+func (d *Driver) checkDNSAlias() error {
+	if !dnsPrefixPattern.MatchString(d.DNSAliasPrefix) {
+		return fmt.Errorf("Invalid qingcloud-dns-alias [%s], only lowercase letters, digits and hyphens are allowed.", d.DNSAliasPrefix)
+	}
+	return nil
+}
+
+// dnsName returns the domain of an alias, prefix.label.domain.
+func dnsName(prefix, label, domain string) string {
+	parts := []string{prefix}
+	for _, part := range []string{label, domain} {
+		if part = strings.Trim(part, "."); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// associateDNSAlias registers the instance under qingcloud-dns-alias.
+func (d *Driver) associateDNSAlias() error {
+	if d.DNSAliasPrefix == "" {
+		return nil
+	}
+	client := d.GetClient()
+	label, domain, err := client.GetDNSLabel()
+	if err != nil {
+		return err
+	}
+	aliasID, err := client.AssociateDNSAlias(&d.DNSAliasPrefix, d.InstanceID)
+	if err != nil {
+		return err
+	}
+	d.DNSAlias = *aliasID
+	d.DNSName = dnsName(d.DNSAliasPrefix, label, domain)
+	log.Infof("Associated DNS alias [%s] to Instance [%s]", d.DNSName, *d.InstanceID)
+	return nil
+}
+
+// dissociateDNSAlias removes the alias of the instance.
+func (d *Driver) dissociateDNSAlias() error {
+	if d.DNSAlias == "" {
+		return nil
+	}
+	if err := d.GetClient().DissociateDNSAlias(&d.DNSAlias); err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("Dissociate DNS alias [%s] fail, err: [%s]", d.DNSName, err.Error())
+	}
+	d.DNSAlias = ""
+	return nil
+}
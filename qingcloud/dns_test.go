@@ -0,0 +1,30 @@
+package qingcloud
+
+import (
+	"testing"
+)
+
+func TestDNSName(t *testing.T) {
+	if name := dnsName("web", "abc123", "qingcloud.com."); name != "web.abc123.qingcloud.com" {
+		t.Errorf("unexpected dns name [%s]", name)
+	}
+	if name := dnsName("web", "", "qingcloud.com"); name != "web.qingcloud.com" {
+		t.Errorf("unexpected dns name [%s]", name)
+	}
+}
+
+func TestCheckDNSAlias(t *testing.T) {
+	d := NewDriver("test", "")
+	for _, prefix := range []string{"web", "web-1", "1web"} {
+		d.DNSAliasPrefix = prefix
+		if err := d.checkDNSAlias(); err != nil {
+			t.Errorf("expect [%s] valid, got %s", prefix, err.Error())
+		}
+	}
+	for _, prefix := range []string{"Web", "-web", "web-", "web.example", "web_1"} {
+		d.DNSAliasPrefix = prefix
+		if err := d.checkDNSAlias(); err == nil {
+			t.Errorf("expect [%s] invalid", prefix)
+		}
+	}
+}
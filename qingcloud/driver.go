@@ -14,6 +14,7 @@ import (
 	"io/ioutil"
 	"os/user"
 	"path"
+	"sort"
 	"time"
 )
 
@@ -59,18 +60,53 @@ var defaultSecurityGroupRules = []*qcservice.SecurityGroupRule{
 
 type Driver struct {
 	*drivers.BaseDriver
-	AccessKeyID     string
-	SecretAccessKey string
-	Zone            string
-	Image           string
-	CPU             int
-	Memory          int
-	LoginKeyPair    string
-	VxNet           string
-	InstanceID      *string
-	EIP             *qcservice.EIP
-	SecurityGroup   *qcservice.SecurityGroup
-	client          Client
+	AccessKeyID       string
+	SecretAccessKey   string
+	Zone              string
+	Image             string
+	ImageSelector     string
+	CPU               int
+	Memory            int
+	LoginKeyPair      string
+	KeyPairCreated    bool
+	ExtraKeyPairs     []string
+	VxNet             string
+	ExtraNics         []string
+	PrimaryVxNet      string
+	CreateVPC         string
+	VPCName           string
+	Router            string
+	DNSAliasPrefix    string
+	UseDNSAlias       bool
+	DNSAlias          string
+	DNSName           string
+	SwarmLB           string
+	SwarmLBPorts      []string
+	LoadBalancer      string
+	LBBackends        []string
+	SharedStorage     []string
+	TTL               string
+	StopTimeout       int
+	StopContainers    bool
+	Nics              []string
+	InstanceID        *string
+	EIP               *qcservice.EIP
+	SecurityGroup     *qcservice.SecurityGroup
+	SnapshotOnRemove  bool
+	Adopted           bool
+	DetachOnRemove    bool
+	Zones             []string
+	SSHBastion        string
+	SSHBastionKeyPath string
+	SSHForwardPort    int
+	DockerForwardPort int
+	UsePrivateAddress bool
+	NoPublicIP        bool
+	client            Client
+	ipRefreshed       time.Time
+	// createdKeyPairs are keypairs the driver created for an instance which
+	// is not in the store, they are deleted on remove.
+	createdKeyPairs []string
 }
 
 type SSHKeyPair struct {
@@ -100,13 +136,13 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.StringFlag{
 			EnvVar: "QINGCLOUD_ZONE",
 			Name:   "qingcloud-zone",
-			Usage:  "QingCloud zone",
+			Usage:  "QingCloud zone, or comma separated zones to fail over to when capacity or quota is exhausted",
 			Value:  defaultZone,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "QINGCLOUD_IMAGE",
 			Name:   "qingcloud-image",
-			Usage:  "Instance image ID",
+			Usage:  "Instance image ID or selector, e.g. ubuntu:16.04, os_family=centos,latest, self:my-golden-*",
 			Value:  defaultImage,
 		},
 		mcnflag.StringFlag{
@@ -115,17 +151,80 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Vxnet id",
 			Value:  defaultVxNet,
 		},
+		mcnflag.StringFlag{
+			Name:  "qingcloud-create-vpc",
+			Usage: "Create the machine in a vxnet of this network, e.g. 192.168.100.0/24, the router and vxnet are created if missing",
+		},
+		mcnflag.StringFlag{
+			Name:  "qingcloud-vpc-name",
+			Usage: "Name of the router and vxnet of qingcloud-create-vpc, shared by machines",
+			Value: defaultVPCName,
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "qingcloud-extra-nic",
+			Usage: "Additional NIC in a vxnet, vxnet-id[:private-ip], can be repeated",
+		},
+		mcnflag.StringFlag{
+			Name:  "qingcloud-primary-vxnet",
+			Usage: "VxNet whose IP is used for ssh and docker, default is qingcloud-vxnet-id",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "QINGCLOUD_LOGIN_KEYPAIR",
 			Name:   "qingcloud-login-keypair",
 			Usage:  "Login keypair id.",
 		},
+		mcnflag.StringSliceFlag{
+			Name:  "qingcloud-extra-keypair",
+			Usage: "Additional keypair id attached to the instance, e.g. for teammates, can be repeated",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "QINGCLOUD_SSH_KEYPATH",
 			Name:   "qingcloud-ssh-keypath",
 			Usage:  "SSH Key for Instance.",
 			Value:  defaultSSHKeyPath,
 		},
+		mcnflag.BoolFlag{
+			Name:  "qingcloud-use-private-address",
+			Usage: "Use the private IP for ssh and docker even if the instance has an EIP",
+		},
+		mcnflag.BoolFlag{
+			Name:  "qingcloud-no-public-ip",
+			Usage: "Don't allocate an EIP for instances in vxnet-0",
+		},
+		mcnflag.StringFlag{
+			Name:  "qingcloud-dns-alias",
+			Usage: "Register the instance under the DNS alias prefix.<dns-label>.<domain>",
+		},
+		mcnflag.BoolFlag{
+			Name:  "qingcloud-use-dns-alias",
+			Usage: "Use the DNS alias instead of the IP for ssh and docker",
+		},
+		mcnflag.StringFlag{
+			Name:  "qingcloud-swarm-lb",
+			Usage: "Register the swarm master as a backend of the load balancer with this name, the load balancer is created if missing",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "qingcloud-swarm-lb-port",
+			Usage: "Additional load balancer port for apps, port or listener-port:backend-port, can be repeated",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "qingcloud-shared-storage",
+			Usage: "Mount an NFS shared target, target-id:mountpoint, can be repeated, iSCSI targets are not supported",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "QINGCLOUD_SSH_BASTION",
+			Name:   "qingcloud-ssh-bastion",
+			Usage:  "Jump host to reach instances in a VPC vxnet, user@host[:port]",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "QINGCLOUD_SSH_BASTION_KEYPATH",
+			Name:   "qingcloud-ssh-bastion-keypath",
+			Usage:  "SSH Key for the jump host, default is the ssh agent and ~/.ssh keys",
+		},
+		mcnflag.IntFlag{
+			Name:  "qingcloud-docker-port-forward",
+			Usage: "Local port forwarded to the docker port through the jump host, 0 disables it",
+		},
 		mcnflag.IntFlag{
 			Name:  "qingcloud-cpu",
 			Usage: "QingCloud cpu count",
@@ -136,6 +235,39 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage: "QingCloud memory size in MB",
 			Value: defaultMemory,
 		},
+		mcnflag.StringFlag{
+			Name:  "qingcloud-instance-id",
+			Usage: "Adopt an existing instance instead of creating a new one",
+		},
+		mcnflag.BoolFlag{
+			Name:  "qingcloud-detach-on-remove",
+			Usage: "Keep the instance and its resources on remove, default for adopted instances",
+		},
+		mcnflag.BoolFlag{
+			Name:  "qingcloud-terminate-adopted",
+			Usage: "Terminate an adopted instance and release its resources on remove, instead of only detaching it",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "QINGCLOUD_SNAPSHOT_ON_REMOVE",
+			Name:   "qingcloud-snapshot-on-remove",
+			Usage:  "Take a final snapshot of the instance disks before remove",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "QINGCLOUD_STOP_TIMEOUT",
+			Name:   "qingcloud-stop-timeout",
+			Usage:  "Seconds to wait for a graceful stop before the instance is force stopped",
+			Value:  defaultOpTimeout,
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "QINGCLOUD_STOP_CONTAINERS",
+			Name:   "qingcloud-stop-containers",
+			Usage:  "Stop the docker containers over ssh before the instance is stopped",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "QINGCLOUD_TTL",
+			Name:   "qingcloud-ttl",
+			Usage:  "Lease of the machine, e.g. 8h, the expiry is recorded in the instance description",
+		},
 	}
 }
 
@@ -144,13 +276,40 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AccessKeyID = flags.String("qingcloud-access-key-id")
 	d.SecretAccessKey = flags.String("qingcloud-secret-access-key")
-	d.Zone = flags.String("qingcloud-zone")
+	d.Zones = splitZones(flags.String("qingcloud-zone"))
+	if len(d.Zones) > 0 {
+		d.Zone = d.Zones[0]
+	}
 	d.VxNet = flags.String("qingcloud-vxnet-id")
+	d.CreateVPC = flags.String("qingcloud-create-vpc")
+	d.VPCName = flags.String("qingcloud-vpc-name")
+	d.ExtraNics = flags.StringSlice("qingcloud-extra-nic")
+	d.PrimaryVxNet = flags.String("qingcloud-primary-vxnet")
 	d.LoginKeyPair = flags.String("qingcloud-login-keypair")
+	d.ExtraKeyPairs = flags.StringSlice("qingcloud-extra-keypair")
 	d.CPU = flags.Int("qingcloud-cpu")
 	d.Memory = flags.Int("qingcloud-memory")
 	d.SSHKeyPath = flags.String("qingcloud-ssh-keypath")
+	d.UsePrivateAddress = flags.Bool("qingcloud-use-private-address")
+	d.NoPublicIP = flags.Bool("qingcloud-no-public-ip")
+	d.DNSAliasPrefix = flags.String("qingcloud-dns-alias")
+	d.UseDNSAlias = flags.Bool("qingcloud-use-dns-alias")
+	d.SwarmLB = flags.String("qingcloud-swarm-lb")
+	d.SwarmLBPorts = flags.StringSlice("qingcloud-swarm-lb-port")
+	d.SharedStorage = flags.StringSlice("qingcloud-shared-storage")
+	d.SSHBastion = flags.String("qingcloud-ssh-bastion")
+	d.SSHBastionKeyPath = flags.String("qingcloud-ssh-bastion-keypath")
+	d.DockerForwardPort = flags.Int("qingcloud-docker-port-forward")
 	d.Image = flags.String("qingcloud-image")
+	d.SnapshotOnRemove = flags.Bool("qingcloud-snapshot-on-remove")
+	d.TTL = flags.String("qingcloud-ttl")
+	d.StopTimeout = flags.Int("qingcloud-stop-timeout")
+	d.StopContainers = flags.Bool("qingcloud-stop-containers")
+	if instanceID := flags.String("qingcloud-instance-id"); instanceID != "" {
+		d.InstanceID = &instanceID
+		d.Adopted = true
+	}
+	d.DetachOnRemove = flags.Bool("qingcloud-detach-on-remove") || (d.Adopted && !flags.Bool("qingcloud-terminate-adopted"))
 	d.SetSwarmConfigFromFlags(flags)
 	return nil
 }
@@ -169,9 +328,37 @@ func NewDriver(hostName, storePath string) *Driver {
 }
 
 func (d *Driver) GetSSHHostname() (string, error) {
+	if d.SSHBastion != "" {
+		port, err := d.GetSSHPort()
+		if err != nil {
+			return "", err
+		}
+		if err := d.ensureTunnel(port, drivers.DefaultSSHPort); err != nil {
+			return "", err
+		}
+		return localhost, nil
+	}
+	if d.UseDNSAlias && d.DNSName != "" {
+		return d.DNSName, nil
+	}
 	return d.GetIP()
 }
 
+// GetSSHPort returns the local end of the bastion tunnel, or the ssh port.
+func (d *Driver) GetSSHPort() (int, error) {
+	if d.SSHBastion == "" {
+		return d.BaseDriver.GetSSHPort()
+	}
+	if d.SSHForwardPort == 0 {
+		port, err := freeLocalPort()
+		if err != nil {
+			return 0, err
+		}
+		d.SSHForwardPort = port
+	}
+	return d.SSHForwardPort, nil
+}
+
 // DriverName returns the name of the driver
 func (d *Driver) DriverName() string {
 	return "qingcloud"
@@ -187,23 +374,157 @@ func (d *Driver) Config() *config.Config {
 
 // PreCreateCheck allows for pre-create operations to make sure a driver is ready for creation
 func (d *Driver) PreCreateCheck() error {
+	if d.Adopted {
+		return d.checkAdopt()
+	}
+	client := d.GetClient()
+	errs := multiError{}
+	if d.SSHBastion != "" {
+		if _, err := parseBastion(d.SSHBastion); err != nil {
+			errs = append(errs, err)
+		}
+	} else if d.DockerForwardPort != 0 {
+		errs = append(errs, errors.New("Param error: qingcloud-docker-port-forward param should work with qingcloud-ssh-bastion param."))
+	}
 	if d.LoginKeyPair != "" {
-		_, err := d.GetClient().DescribeKeyPair(&d.LoginKeyPair)
+		keyPair, err := client.DescribeKeyPair(&d.LoginKeyPair)
 		if err != nil {
-			return err
+			errs = append(errs, err)
 		}
 		if d.SSHKeyPath == "" {
-			return errors.New("Param error: qingcloud-login-keypair param should work with qingcloud-ssh-keypath param.")
+			errs = append(errs, errors.New("Param error: qingcloud-login-keypair param should work with qingcloud-ssh-keypath param."))
+		} else if err == nil && keyPair.PubKey != nil {
+			if err := d.checkLoginKeyPair(*keyPair.PubKey); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, keyPairID := range d.ExtraKeyPairs {
+		if _, err := client.DescribeKeyPair(stringPtr(keyPairID)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if d.DNSAliasPrefix != "" {
+		if err := d.checkDNSAlias(); err != nil {
+			errs = append(errs, err)
+		}
+	} else if d.UseDNSAlias {
+		errs = append(errs, errors.New("Param error: qingcloud-use-dns-alias param should work with qingcloud-dns-alias param."))
+	}
+	if d.TTL != "" {
+		if err := d.checkTTL(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	if d.VxNet == "" {
-		return errors.New("Param qingcloud-vxnet-id required.")
+	if d.SwarmLB != "" {
+		if _, err := d.lbPorts(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if d.CreateVPC != "" {
+		if err := d.checkVPC(); err != nil {
+			errs = append(errs, err)
+		}
+	} else if d.VxNet == "" {
+		errs = append(errs, errors.New("Param qingcloud-vxnet-id required."))
+	} else if d.VxNet != defaultVxNet {
+		if _, err := client.DescribeVxNet(&d.VxNet); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, d.checkNics()...)
+	errs = append(errs, d.checkSharedStorage()...)
+	if err := d.resolveImage(); err != nil {
+		errs = append(errs, err)
 	}
+	errs = append(errs, d.checkQuota()...)
 
-	return nil
+	return errs.errorOrNil()
+}
+
+// checkQuota returns an error for each resource the create needs whose quota
+// is used up. With failover zones, shortfalls are only logged.
+func (d *Driver) checkQuota() []error {
+	need := map[string]int{"instance": 1}
+	if d.VxNet == defaultVxNet && d.CreateVPC == "" {
+		if !d.NoPublicIP {
+			need["eip"] = 1
+		}
+		need["security_group"] = 1
+	}
+	if d.LoginKeyPair == "" {
+		need["keypair"] = 1
+	}
+	resourceTypes := []string{}
+	for t := range need {
+		resourceTypes = append(resourceTypes, t)
+	}
+	sort.Strings(resourceTypes)
+	left, err := d.GetClient().GetQuotaLeft(resourceTypes)
+	if err != nil {
+		log.Warnf("Get quota left in zone [%s] fail, skip quota check, err: [%s]", d.Zone, err.Error())
+		return nil
+	}
+	errs := []error{}
+	for _, t := range resourceTypes {
+		l, ok := left[t]
+		if !ok || l >= need[t] {
+			continue
+		}
+		err := fmt.Errorf("Quota of [%s] is used up in zone [%s], left %d, need %d.", t, d.Zone, l, need[t])
+		if len(d.Zones) > 1 {
+			log.Warnf("%s Will fail over to the next zone.", err.Error())
+			continue
+		}
+		errs = append(errs, err)
+	}
+	return errs
 }
 
 func (d *Driver) Create() error {
+	if d.Adopted {
+		log.Infof("Adopting QingCloud Instance [%s]...", *d.InstanceID)
+		return d.adoptInstance()
+	}
+
+	if d.CreateVPC != "" {
+		if err := d.ensureVPC(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.createInZones(); err != nil {
+		return err
+	}
+
+	log.Infof("Created Instance [%s] IPAddress: [%s] Zone: [%s]",
+		*d.InstanceID, d.IPAddress, d.Zone)
+	if err := d.startLease(); err != nil {
+		return err
+	}
+	if err := d.attachExtraKeyPairs(); err != nil {
+		return err
+	}
+	if err := d.attachExtraNics(); err != nil {
+		return err
+	}
+	if err := d.associateDNSAlias(); err != nil {
+		return err
+	}
+	if err := d.joinLoadBalancer(); err != nil {
+		return err
+	}
+	d.checkOSEnv()
+	if err := d.mountSharedStorage(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createInstance creates the keypair, instance, EIP and security group of the
+// machine in the current zone.
+func (d *Driver) createInstance() error {
 	log.Infof("Creating SSH key...")
 
 	if d.LoginKeyPair == "" {
@@ -211,6 +532,7 @@ func (d *Driver) Create() error {
 		if err != nil {
 			return err
 		}
+		d.KeyPairCreated = true
 	}
 
 	log.Infof("Creating QingCloud Instance...")
@@ -229,33 +551,32 @@ func (d *Driver) Create() error {
 		return err
 	}
 	d.InstanceID = ins.InstanceID
+	d.tagResource("instance", d.InstanceID)
 
 	if d.VxNet == defaultVxNet {
-		eip, err := client.BindEIP(d.InstanceID)
-		if err != nil {
-			return err
+		if !d.NoPublicIP {
+			eip, err := client.BindEIP(d.InstanceID)
+			if err != nil {
+				return err
+			}
+			log.Infof("Bind EIP [%s] to Instance [%s]", *eip.EIPAddr, *d.InstanceID)
+			ins.EIP = eip
+			d.EIP = eip
+			d.tagResource("eip", eip.EIPID)
 		}
-		log.Infof("Bind EIP [%s] to Instance [%s]", *eip.EIPAddr, *d.InstanceID)
-		ins.EIP = eip
-		d.EIP = eip
 		sg, err := client.BindSecurityGroup(d.InstanceID, defaultSecurityGroupRules)
 		if err != nil {
 			return err
 		}
 		d.SecurityGroup = sg
+		d.tagResource("security_group", sg.SecurityGroupID)
 		log.Infof("Bind SecurityGroup [%s] to Instance [%s]", *sg.SecurityGroupID, *d.InstanceID)
 	}
 
-	d.IPAddress = *ins.VxNets[0].PrivateIP
-	if ins.EIP != nil && ins.EIP.EIPAddr != nil {
-		d.IPAddress = *ins.EIP.EIPAddr
-	}
+	d.IPAddress = d.instanceAddress(ins)
+	d.ipRefreshed = time.Now()
 	d.MachineName = *d.InstanceID
 
-	log.Infof("Created Instance [%s] IPAddress: [%s]",
-		*d.InstanceID, d.IPAddress)
-	d.checkOSEnv()
-
 	return nil
 }
 
@@ -299,9 +620,12 @@ func (d *Driver) checkOSEnv() error {
 	return nil
 }
 
+// newClient creates the client of a zone, replaced in tests.
+var newClient = NewClient
+
 func (d *Driver) GetClient() Client {
 	if d.client == nil {
-		client, err := NewClient(d.Config(), d.Zone)
+		client, err := newClient(d.Config(), d.Zone)
 		if err != nil {
 			panic(fmt.Sprintf("init client error: %s", err.Error()))
 		}
@@ -310,6 +634,17 @@ func (d *Driver) GetClient() Client {
 	return d.client
 }
 
+// zoneDriver returns a copy of the driver for the zone, the client is kept
+// only if the zone is the same.
+func (d *Driver) zoneDriver(zone string) *Driver {
+	zd := *d
+	if zone != d.Zone {
+		zd.Zone = zone
+		zd.client = nil
+	}
+	return &zd
+}
+
 func (d *Driver) getInstance() (*qcservice.Instance, error) {
 	return d.GetClient().DescribeInstance(d.InstanceID)
 }
@@ -343,9 +678,18 @@ func (d *Driver) createSSHKey() error {
 		return err
 	}
 	d.LoginKeyPair = *keyPairID
+	d.tagResource("keypair", keyPairID)
 	return nil
 }
 
+// tagResource attaches the driver tag to a resource created by the driver.
+func (d *Driver) tagResource(resourceType string, resourceID *string) {
+	err := d.GetClient().TagResources(DefaultTagName, resourceType, []*string{resourceID})
+	if err != nil {
+		log.Warnf("Tag %s [%s] fail, err: [%s]", resourceType, *resourceID, err.Error())
+	}
+}
+
 func (d *Driver) publicSSHKeyPath() string {
 	return d.GetSSHKeyPath() + ".pub"
 }
@@ -353,6 +697,15 @@ func (d *Driver) publicSSHKeyPath() string {
 // GetURL returns a Docker compatible host URL for connecting to this host
 // e.g. tcp://1.2.3.4:2376
 func (d *Driver) GetURL() (string, error) {
+	if d.SSHBastion != "" && d.DockerForwardPort != 0 {
+		if err := d.ensureTunnel(d.DockerForwardPort, dockerPort); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("tcp://%s:%d", localhostName, d.DockerForwardPort), nil
+	}
+	if d.UseDNSAlias && d.DNSName != "" {
+		return fmt.Sprintf("tcp://%s:%d", d.DNSName, dockerPort), nil
+	}
 	ip, err := d.GetIP()
 	if err != nil {
 		return "", err
@@ -367,22 +720,12 @@ func (d *Driver) GetURL() (string, error) {
 func (d *Driver) GetState() (state.State, error) {
 	i, err := d.getInstance()
 	if err != nil {
+		if isNotFoundError(err) {
+			return state.None, fmt.Errorf("Instance [%s] does not exist, the machine is gone, remove it with docker-machine rm.", *d.InstanceID)
+		}
 		return state.None, err
 	}
-	if i.Status == nil {
-		return state.None, nil
-	}
-	switch *i.Status {
-	case INSTANCE_STATUS_PENDING:
-		return state.Starting, nil
-	case INSTANCE_STATUS_RUNNING:
-		return state.Running, nil
-	case INSTANCE_STATUS_STOPPED:
-		return state.Stopped, nil
-	case INSTANCE_STATUS_SUSPENDED, INSTANCE_STATUS_TERMINATED, INSTANCE_STATUS_CEASED:
-		return state.Error, nil
-	}
-	return state.Error, nil
+	return instanceState(i)
 }
 
 // Kill stops a host forcefully
@@ -392,25 +735,90 @@ func (d *Driver) Kill() error {
 
 // Remove a host
 func (d *Driver) Remove() error {
-	err := d.GetClient().TerminateInstance(d.InstanceID)
-	if err != nil {
+	defer d.closeTunnels()
+	if d.DetachOnRemove {
+		log.Infof("Detach Instance [%s] from docker-machine, the instance and its resources are kept", stringValue(d.InstanceID))
+		return nil
+	}
+	errs := multiError{}
+	if d.InstanceID == nil {
+		// the create failed before the instance was run
+		log.Warnf("Machine [%s] has no instance, releasing its resources", d.MachineName)
+	} else if err := d.removeInstance(&errs); err != nil {
 		return err
 	}
+	client := d.GetClient()
 	if d.EIP != nil {
-		err := d.GetClient().ReleaseEIP(d.EIP.EIPID)
-		if err != nil {
-			log.Errorf("Release EIP [%+v] fail, err: [%s]", *d.EIP, err.Error())
+		if err := client.ReleaseEIP(d.EIP.EIPID); err != nil && !isNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("Release EIP [%s] fail, err: [%s]", stringValue(d.EIP.EIPID), err.Error()))
 		}
 	}
 	if d.SecurityGroup != nil {
-		err := d.GetClient().DeleteSecurityGroup(d.SecurityGroup.SecurityGroupID)
+		if err := client.DeleteSecurityGroup(d.SecurityGroup.SecurityGroupID); err != nil && !isNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("Delete SecurityGroup [%s] fail, err: [%s]", stringValue(d.SecurityGroup.SecurityGroupID), err.Error()))
+		}
+	}
+	keyPairs := d.createdKeyPairs
+	if d.KeyPairCreated && d.LoginKeyPair != "" {
+		keyPairs = append([]string{d.LoginKeyPair}, keyPairs...)
+	}
+	for _, keyPairID := range keyPairs {
+		if err := client.DeleteKeyPair(stringPtr(keyPairID)); err != nil && !isNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("Delete KeyPair [%s] fail, err: [%s]", keyPairID, err.Error()))
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// removeInstance takes the final snapshot, cleans up what is bound to the
+// instance and terminates it. Cleanup errors are collected in errs, an error is
+// returned if the instance is not terminated, as its EIP and security group
+// are still bound to it.
+func (d *Driver) removeInstance(errs *multiError) error {
+	gone, err := d.isGone()
+	if err != nil {
+		return err
+	}
+	if gone {
+		log.Warnf("Instance [%s] is already terminated or missing, cleaning up its resources", *d.InstanceID)
+	} else if d.SnapshotOnRemove {
+		snapshotIDs, err := d.Backup()
 		if err != nil {
-			log.Errorf("Delete SecurityGroup [%+v] fail, err: [%s]", *d.SecurityGroup, err.Error())
+			return fmt.Errorf("Take final snapshot of Instance [%s] error: [%s]", *d.InstanceID, err.Error())
 		}
+		log.Infof("Took final snapshots %v of Instance [%s]", snapshotIDs, *d.InstanceID)
+	}
+	cleanups := []func() error{d.leaveLoadBalancer, d.dissociateDNSAlias, d.removeNics}
+	if !gone {
+		// keypairs of a terminated instance are already detached
+		cleanups = append([]func() error{d.detachExtraKeyPairs}, cleanups...)
+	}
+	for _, cleanup := range cleanups {
+		if err := cleanup(); err != nil {
+			*errs = append(*errs, err)
+		}
+	}
+	if gone {
+		return nil
+	}
+	if err := d.GetClient().TerminateInstance(d.InstanceID); err != nil && !isNotFoundError(err) {
+		return append(*errs, err)
 	}
 	return nil
 }
 
+// isGone returns true if the instance does not exist, or is terminated or ceased.
+func (d *Driver) isGone() (bool, error) {
+	ins, err := d.getInstance()
+	if err != nil {
+		if isNotFoundError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return instanceGone(stringValue(ins.Status)), nil
+}
+
 // Restart a host. This may just call Stop(); Start() if the provider does not
 // have any special restart behaviour.
 func (d *Driver) Restart() error {
@@ -424,5 +832,26 @@ func (d *Driver) Start() error {
 
 // Stop a host gracefully
 func (d *Driver) Stop() error {
-	return d.GetClient().StopInstance(d.InstanceID, false)
+	if d.StopContainers {
+		d.stopContainers()
+	}
+	timeout := d.StopTimeout
+	if timeout <= 0 {
+		timeout = defaultOpTimeout
+	}
+	err := d.GetClient().StopInstanceWithin(d.InstanceID, timeout)
+	if !isWaitTimeoutError(err) {
+		return err
+	}
+	log.Warnf("Instance [%s] did not stop gracefully in qingcloud-stop-timeout [%d] seconds, force stopping it", *d.InstanceID, timeout)
+	return d.GetClient().StopInstance(d.InstanceID, true)
+}
+
+// stopContainers stops the running docker containers so a graceful stop
+// doesn't cut their writes, failures are logged and the stop goes on.
+func (d *Driver) stopContainers() {
+	log.Infof("Stopping docker containers on Instance [%s]...", *d.InstanceID)
+	if _, err := drivers.RunSSHCommandFromDriver(d, "docker ps -q | xargs -r docker stop"); err != nil {
+		log.Warnf("Stop docker containers on Instance [%s] fail, err: [%s]", *d.InstanceID, err.Error())
+	}
 }
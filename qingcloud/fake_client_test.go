@@ -0,0 +1,557 @@
+package qingcloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yunify/qingcloud-sdk-go/config"
+	qcerrors "github.com/yunify/qingcloud-sdk-go/request/errors"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// fakeClient is an in-memory Client for driver tests. It records the calls
+// as "Method arg ..." and returns the error set for the method, methods it
+// doesn't implement panic through the nil embedded Client.
+type fakeClient struct {
+	Client
+	instances map[string]*qcservice.Instance
+	snapshots map[string][]*qcservice.Snapshot
+	keyPairs  map[string]*qcservice.KeyPair
+	eips      []*qcservice.EIP
+	sgs       []*qcservice.SecurityGroup
+	images    map[string]*qcservice.Image
+	zones     []string
+	vxnets    map[string]*qcservice.VxNet
+	routers   []*qcservice.Router
+	s2Targets map[string]*qcservice.S2SharedTarget
+	s2Servers map[string]*qcservice.S2Server
+	// routerVxNets holds the vxnets joined to a router by router ID.
+	routerVxNets map[string][]*qcservice.RouterVxNet
+	lbs          map[string]*qcservice.LoadBalancer
+	backends     map[string][]*qcservice.LoadBalancerBackend
+	// quota is the quota left by resource type, GetQuotaLeft fails without it.
+	quota map[string]int
+	// tagged holds IDs of the resources with the driver tag.
+	tagged map[string]bool
+	// deleted holds IDs of the deleted resources, deleting them again fails
+	// with resource not found like QingCloud.
+	deleted map[string]bool
+	errs    map[string]error
+	calls   []string
+}
+
+func newFakeClient(instances ...*qcservice.Instance) *fakeClient {
+	c := &fakeClient{
+		instances:    map[string]*qcservice.Instance{},
+		snapshots:    map[string][]*qcservice.Snapshot{},
+		keyPairs:     map[string]*qcservice.KeyPair{},
+		images:       map[string]*qcservice.Image{},
+		vxnets:       map[string]*qcservice.VxNet{},
+		routerVxNets: map[string][]*qcservice.RouterVxNet{},
+		s2Targets:    map[string]*qcservice.S2SharedTarget{},
+		s2Servers:    map[string]*qcservice.S2Server{},
+		lbs:          map[string]*qcservice.LoadBalancer{},
+		backends:     map[string][]*qcservice.LoadBalancerBackend{},
+		tagged:       map[string]bool{},
+		deleted:      map[string]bool{},
+		errs:         map[string]error{},
+	}
+	for _, ins := range instances {
+		c.instances[*ins.InstanceID] = ins
+	}
+	return c
+}
+
+// newFakeDriver returns a driver of the instance using the fake client.
+func newFakeDriver(c *fakeClient, instanceID string) *Driver {
+	d := NewDriver("test", "")
+	d.client = c
+	if instanceID != "" {
+		d.InstanceID = stringPtr(instanceID)
+	}
+	return d
+}
+
+// useZoneClients makes drivers without a client use the fake client of their
+// zone, it returns a func restoring the real clients.
+func useZoneClients(clients map[string]*fakeClient) func() {
+	orig := newClient
+	newClient = func(_ *config.Config, zone string) (Client, error) {
+		c, ok := clients[zone]
+		if !ok {
+			return nil, fmt.Errorf("No fake client of zone [%s]", zone)
+		}
+		return c, nil
+	}
+	return func() { newClient = orig }
+}
+
+func (c *fakeClient) call(method string, args ...interface{}) error {
+	parts := []string{method}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case *string:
+			parts = append(parts, stringValue(v))
+		case []*string:
+			for _, s := range v {
+				parts = append(parts, stringValue(s))
+			}
+		default:
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	c.calls = append(c.calls, strings.Join(parts, " "))
+	return c.errs[method]
+}
+
+// delete records the call and marks the resources deleted, it fails with
+// resource not found if one is already deleted.
+func (c *fakeClient) delete(method string, ids ...*string) error {
+	if err := c.call(method, ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if c.deleted[stringValue(id)] {
+			return &qcerrors.QingCloudError{RetCode: retCodeResourceNotFound, Message: "resource not found"}
+		}
+	}
+	for _, id := range ids {
+		c.deleted[stringValue(id)] = true
+	}
+	return nil
+}
+
+// called returns true if a call was recorded as "Method arg ...".
+func (c *fakeClient) called(call string) bool {
+	for _, recorded := range c.calls {
+		if recorded == call {
+			return true
+		}
+	}
+	return false
+}
+
+// index returns the position of the first call of the method, or -1.
+func (c *fakeClient) index(method string) int {
+	for i, recorded := range c.calls {
+		if recorded == method || strings.HasPrefix(recorded, method+" ") {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *fakeClient) DescribeInstance(instanceID *string) (*qcservice.Instance, error) {
+	if err := c.call("DescribeInstance", instanceID); err != nil {
+		return nil, err
+	}
+	ins, ok := c.instances[stringValue(instanceID)]
+	if !ok {
+		return nil, &instanceNotFoundError{InstanceID: stringValue(instanceID)}
+	}
+	return ins, nil
+}
+
+// RunInstance runs a running instance named i-<n> in the default vxnet.
+func (c *fakeClient) RunInstance(arg *RunInstanceArg) (*qcservice.Instance, error) {
+	if err := c.call("RunInstance", arg.ImageID, arg.LoginKeyPair); err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("i-%d", len(c.instances)+1)
+	ins := &qcservice.Instance{
+		InstanceID: stringPtr(id),
+		Status:     stringPtr(INSTANCE_STATUS_RUNNING),
+		VxNets:     []*qcservice.VxNet{{VxNetID: stringPtr(defaultVxNet), PrivateIP: stringPtr("10.0.0.2")}},
+	}
+	c.instances[id] = ins
+	return ins, nil
+}
+
+func (c *fakeClient) BindEIP(instanceID *string) (*qcservice.EIP, error) {
+	if err := c.call("BindEIP", instanceID); err != nil {
+		return nil, err
+	}
+	return &qcservice.EIP{EIPID: stringPtr("eip-" + *instanceID), EIPAddr: stringPtr("1.2.3.4")}, nil
+}
+
+func (c *fakeClient) BindSecurityGroup(instanceID *string, rules []*qcservice.SecurityGroupRule) (*qcservice.SecurityGroup, error) {
+	if err := c.call("BindSecurityGroup", instanceID); err != nil {
+		return nil, err
+	}
+	return &qcservice.SecurityGroup{SecurityGroupID: stringPtr("sg-" + *instanceID)}, nil
+}
+
+// CreateKeyPair creates the keypair kp-<name>.
+func (c *fakeClient) CreateKeyPair(keyPairName *string, publicKey *string) (*string, error) {
+	if err := c.call("CreateKeyPair", keyPairName); err != nil {
+		return nil, err
+	}
+	id := "kp-" + *keyPairName
+	c.keyPairs[id] = &qcservice.KeyPair{KeyPairID: &id, KeyPairName: keyPairName, PubKey: publicKey}
+	return &id, nil
+}
+
+// DescribeImages returns the images of the filter IDs, or all images.
+func (c *fakeClient) DescribeImages(filter *ImageFilter) ([]*qcservice.Image, error) {
+	if err := c.call("DescribeImages", filter.ImageIDs); err != nil {
+		return nil, err
+	}
+	images := []*qcservice.Image{}
+	for id, image := range c.images {
+		if len(filter.ImageIDs) == 0 || filter.ImageIDs[0] == id {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+func (c *fakeClient) setStatus(instanceID *string, status string) {
+	if ins, ok := c.instances[stringValue(instanceID)]; ok {
+		ins.Status = stringPtr(status)
+	}
+}
+
+func (c *fakeClient) StartInstance(instanceID *string) error {
+	if err := c.call("StartInstance", instanceID); err != nil {
+		return err
+	}
+	c.setStatus(instanceID, INSTANCE_STATUS_RUNNING)
+	return nil
+}
+
+func (c *fakeClient) StopInstance(instanceID *string, force bool) error {
+	if err := c.call("StopInstance", instanceID, force); err != nil {
+		return err
+	}
+	c.setStatus(instanceID, INSTANCE_STATUS_STOPPED)
+	return nil
+}
+
+func (c *fakeClient) StopInstanceWithin(instanceID *string, timeout int) error {
+	if err := c.call("StopInstanceWithin", instanceID, timeout); err != nil {
+		return err
+	}
+	c.setStatus(instanceID, INSTANCE_STATUS_STOPPED)
+	return nil
+}
+
+func (c *fakeClient) TerminateInstance(instanceID *string) error {
+	if err := c.call("TerminateInstance", instanceID); err != nil {
+		return err
+	}
+	c.setStatus(instanceID, INSTANCE_STATUS_TERMINATED)
+	return nil
+}
+
+// ResetInstance boots the instance with the reinstalled image, like QingCloud.
+func (c *fakeClient) ResetInstance(instanceID *string, loginKeyPair *string) error {
+	if err := c.call("ResetInstance", instanceID, loginKeyPair); err != nil {
+		return err
+	}
+	c.setStatus(instanceID, INSTANCE_STATUS_RUNNING)
+	return nil
+}
+
+func (c *fakeClient) WaitInstanceStatus(instanceID *string, status string) error {
+	return c.call("WaitInstanceStatus", instanceID, status)
+}
+
+func (c *fakeClient) ReleaseEIP(eipID *string) error {
+	return c.delete("ReleaseEIP", eipID)
+}
+
+func (c *fakeClient) DeleteSecurityGroup(sgID *string) error {
+	return c.delete("DeleteSecurityGroup", sgID)
+}
+
+func (c *fakeClient) DeleteKeyPair(keyPairID *string) error {
+	return c.delete("DeleteKeyPair", keyPairID)
+}
+
+func (c *fakeClient) DissociateDNSAlias(dnsAliasID *string) error {
+	return c.delete("DissociateDNSAlias", dnsAliasID)
+}
+
+func (c *fakeClient) DetachNics(nicIDs []*string) error {
+	return c.call("DetachNics", nicIDs)
+}
+
+func (c *fakeClient) DeleteNics(nicIDs []*string) error {
+	return c.delete("DeleteNics", nicIDs...)
+}
+
+func (c *fakeClient) DescribeLoadBalancer(loadBalancerID *string) (*qcservice.LoadBalancer, error) {
+	if err := c.call("DescribeLoadBalancer", loadBalancerID); err != nil {
+		return nil, err
+	}
+	lb, ok := c.lbs[stringValue(loadBalancerID)]
+	if !ok || c.deleted[stringValue(loadBalancerID)] {
+		return nil, &resourceNotFoundError{Type: "LoadBalancer", ID: stringValue(loadBalancerID)}
+	}
+	return lb, nil
+}
+
+func (c *fakeClient) UpdateLoadBalancer(loadBalancerID *string) error {
+	return c.call("UpdateLoadBalancer", loadBalancerID)
+}
+
+func (c *fakeClient) DeleteLoadBalancer(loadBalancerID *string) error {
+	return c.delete("DeleteLoadBalancer", loadBalancerID)
+}
+
+// DescribeLoadBalancerBackends returns the backends which are not deleted.
+func (c *fakeClient) DescribeLoadBalancerBackends(loadBalancerID *string) ([]*qcservice.LoadBalancerBackend, error) {
+	if err := c.call("DescribeLoadBalancerBackends", loadBalancerID); err != nil {
+		return nil, err
+	}
+	if c.deleted[stringValue(loadBalancerID)] {
+		return nil, &qcerrors.QingCloudError{RetCode: retCodeResourceNotFound, Message: "resource not found"}
+	}
+	backends := []*qcservice.LoadBalancerBackend{}
+	for _, backend := range c.backends[stringValue(loadBalancerID)] {
+		if !c.deleted[stringValue(backend.LoadBalancerBackendID)] {
+			backends = append(backends, backend)
+		}
+	}
+	return backends, nil
+}
+
+func (c *fakeClient) DeleteLoadBalancerBackends(backendIDs []*string) error {
+	return c.delete("DeleteLoadBalancerBackends", backendIDs...)
+}
+
+func (c *fakeClient) DescribeKeyPair(keyPairID *string) (*qcservice.KeyPair, error) {
+	if err := c.call("DescribeKeyPair", keyPairID); err != nil {
+		return nil, err
+	}
+	keyPair, ok := c.keyPairs[stringValue(keyPairID)]
+	if !ok {
+		return nil, &resourceNotFoundError{Type: "KeyPair", ID: stringValue(keyPairID)}
+	}
+	return keyPair, nil
+}
+
+func (c *fakeClient) ListEIPs(tagged bool) ([]*qcservice.EIP, error) {
+	if err := c.call("ListEIPs", tagged); err != nil {
+		return nil, err
+	}
+	eips := []*qcservice.EIP{}
+	for _, eip := range c.eips {
+		if !tagged || c.tagged[*eip.EIPID] {
+			eips = append(eips, eip)
+		}
+	}
+	return eips, nil
+}
+
+func (c *fakeClient) ListSecurityGroups(tagged bool) ([]*qcservice.SecurityGroup, error) {
+	if err := c.call("ListSecurityGroups", tagged); err != nil {
+		return nil, err
+	}
+	sgs := []*qcservice.SecurityGroup{}
+	for _, sg := range c.sgs {
+		if !tagged || c.tagged[*sg.SecurityGroupID] {
+			sgs = append(sgs, sg)
+		}
+	}
+	return sgs, nil
+}
+
+// ListKeyPairs returns the keypairs sorted by ID.
+func (c *fakeClient) ListKeyPairs(tagged bool) ([]*qcservice.KeyPair, error) {
+	if err := c.call("ListKeyPairs", tagged); err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for id := range c.keyPairs {
+		if !tagged || c.tagged[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	keyPairs := []*qcservice.KeyPair{}
+	for _, id := range ids {
+		keyPairs = append(keyPairs, c.keyPairs[id])
+	}
+	return keyPairs, nil
+}
+
+func (c *fakeClient) DetachKeyPairs(instanceID *string, keyPairIDs []*string) error {
+	return c.call("DetachKeyPairs", instanceID, keyPairIDs)
+}
+
+func (c *fakeClient) CreateSnapshots(resourceIDs []*string, snapshotName *string) ([]*string, error) {
+	if err := c.call("CreateSnapshots", resourceIDs); err != nil {
+		return nil, err
+	}
+	ids := []*string{}
+	for _, id := range resourceIDs {
+		ids = append(ids, stringPtr("ss-"+*id))
+	}
+	return ids, nil
+}
+
+func (c *fakeClient) DescribeSnapshots(resourceID *string) ([]*qcservice.Snapshot, error) {
+	if err := c.call("DescribeSnapshots", resourceID); err != nil {
+		return nil, err
+	}
+	return c.snapshots[stringValue(resourceID)], nil
+}
+
+func (c *fakeClient) ApplySnapshots(snapshotIDs []*string) error {
+	return c.call("ApplySnapshots", snapshotIDs)
+}
+
+func (c *fakeClient) CreateVolumeFromSnapshot(snapshotID *string, volumeName *string) (*string, error) {
+	if err := c.call("CreateVolumeFromSnapshot", snapshotID); err != nil {
+		return nil, err
+	}
+	return stringPtr("vol-" + *snapshotID), nil
+}
+
+func (c *fakeClient) GetQuotaLeft(resourceTypes []string) (map[string]int, error) {
+	if err := c.call("GetQuotaLeft", strings.Join(resourceTypes, ",")); err != nil {
+		return nil, err
+	}
+	if c.quota == nil {
+		return nil, fmt.Errorf("No quota")
+	}
+	return c.quota, nil
+}
+
+func (c *fakeClient) DescribeVxNet(vxnetID *string) (*qcservice.VxNet, error) {
+	if err := c.call("DescribeVxNet", vxnetID); err != nil {
+		return nil, err
+	}
+	vxnet, ok := c.vxnets[stringValue(vxnetID)]
+	if !ok {
+		return nil, &resourceNotFoundError{Type: "VxNet", ID: stringValue(vxnetID)}
+	}
+	return vxnet, nil
+}
+
+func (c *fakeClient) AllocateEIP(eipName *string) (*qcservice.EIP, error) {
+	if err := c.call("AllocateEIP", eipName); err != nil {
+		return nil, err
+	}
+	return &qcservice.EIP{EIPID: stringPtr("eip-" + *eipName), EIPAddr: stringPtr("1.2.3.4")}, nil
+}
+
+// ListRouters lists the routers, only the ones in tagged when tagged is set.
+func (c *fakeClient) ListRouters(tagged bool) ([]*qcservice.Router, error) {
+	if err := c.call("ListRouters", tagged); err != nil {
+		return nil, err
+	}
+	routers := []*qcservice.Router{}
+	for _, router := range c.routers {
+		if !tagged || c.tagged[*router.RouterID] {
+			routers = append(routers, router)
+		}
+	}
+	return routers, nil
+}
+
+// CreateRouter creates the router rtr-<name>.
+func (c *fakeClient) CreateRouter(routerName *string) (*string, error) {
+	if err := c.call("CreateRouter", routerName); err != nil {
+		return nil, err
+	}
+	router := &qcservice.Router{RouterID: stringPtr("rtr-" + *routerName), RouterName: routerName}
+	c.routers = append(c.routers, router)
+	return router.RouterID, nil
+}
+
+func (c *fakeClient) SetRouterEIP(routerID *string, eipID *string) error {
+	return c.call("SetRouterEIP", routerID, eipID)
+}
+
+func (c *fakeClient) DeleteRouter(routerID *string) error {
+	return c.delete("DeleteRouter", routerID)
+}
+
+// CreateVxNet creates the vxnet vxnet-<name>.
+func (c *fakeClient) CreateVxNet(vxnetName *string) (*string, error) {
+	if err := c.call("CreateVxNet", vxnetName); err != nil {
+		return nil, err
+	}
+	vxnetID := stringPtr("vxnet-" + *vxnetName)
+	c.vxnets[*vxnetID] = &qcservice.VxNet{VxNetID: vxnetID, VxNetName: vxnetName}
+	return vxnetID, nil
+}
+
+func (c *fakeClient) JoinRouter(routerID *string, vxnetID *string, ipNetwork *string) error {
+	if err := c.call("JoinRouter", routerID, vxnetID, ipNetwork); err != nil {
+		return err
+	}
+	c.routerVxNets[*routerID] = append(c.routerVxNets[*routerID], &qcservice.RouterVxNet{RouterID: routerID, VxNetID: vxnetID, IPNetwork: ipNetwork})
+	return nil
+}
+
+func (c *fakeClient) LeaveRouter(routerID *string, vxnetIDs []*string) error {
+	if err := c.call("LeaveRouter", routerID, vxnetIDs); err != nil {
+		return err
+	}
+	delete(c.routerVxNets, *routerID)
+	return nil
+}
+
+func (c *fakeClient) DescribeRouterVxNets(routerID *string) ([]*qcservice.RouterVxNet, error) {
+	if err := c.call("DescribeRouterVxNets", routerID); err != nil {
+		return nil, err
+	}
+	return c.routerVxNets[*routerID], nil
+}
+
+// CountVxNetInstances counts the instances in the vxnet.
+func (c *fakeClient) CountVxNetInstances(vxnetID *string) (int, error) {
+	if err := c.call("CountVxNetInstances", vxnetID); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, ins := range c.instances {
+		for _, vxnet := range ins.VxNets {
+			if stringValue(vxnet.VxNetID) == *vxnetID {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (c *fakeClient) DeleteVxNets(vxnetIDs []*string) error {
+	return c.delete("DeleteVxNets", vxnetIDs...)
+}
+
+func (c *fakeClient) DescribeS2SharedTarget(targetID *string) (*qcservice.S2SharedTarget, error) {
+	if err := c.call("DescribeS2SharedTarget", targetID); err != nil {
+		return nil, err
+	}
+	target, ok := c.s2Targets[*targetID]
+	if !ok {
+		return nil, fmt.Errorf("S2 shared target with id [%s] not exist.", *targetID)
+	}
+	return target, nil
+}
+
+func (c *fakeClient) DescribeS2Server(serverID *string) (*qcservice.S2Server, error) {
+	if err := c.call("DescribeS2Server", serverID); err != nil {
+		return nil, err
+	}
+	server, ok := c.s2Servers[*serverID]
+	if !ok {
+		return nil, fmt.Errorf("S2 server with id [%s] not exist.", *serverID)
+	}
+	return server, nil
+}
+
+func (c *fakeClient) DescribeZones() ([]string, error) {
+	if err := c.call("DescribeZones"); err != nil {
+		return nil, err
+	}
+	return c.zones, nil
+}
+
+func (c *fakeClient) TagResources(tagName string, resourceType string, resourceIDs []*string) error {
+	return c.call("TagResources", tagName, resourceType, resourceIDs)
+}
@@ -0,0 +1,293 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// instanceIDPattern matches resource names the driver derives from instance
+// IDs, see allocateEIP and createSecurityGroup.
+var instanceIDPattern = regexp.MustCompile(`^i-[a-z0-9]+$`)
+
+// minOrphanAge is how old a resource whose owner is unknown must be before gc
+// deletes it, younger ones may belong to a create which is still running.
+const minOrphanAge = time.Hour
+
+// orphan is a driver managed resource whose owning instance is gone.
+type orphan struct {
+	Type     string
+	ID       string
+	Name     string
+	Instance string
+	// Keep is why the orphan is only reported and not deleted.
+	Keep    string
+	network *vpcNetwork
+}
+
+// gcResources holds the resources of a zone inspected by gc.
+type gcResources struct {
+	EIPs           []*qcservice.EIP
+	SecurityGroups []*qcservice.SecurityGroup
+	KeyPairs       []*qcservice.KeyPair
+	Networks       []*vpcNetwork
+	// Tagged holds IDs of the resources with the driver tag.
+	Tagged map[string]bool
+	// InstanceStatus holds the status of the owning instances returned by
+	// DescribeInstances.
+	InstanceStatus map[string]string
+	Now            time.Time
+}
+
+func instanceGone(status string) bool {
+	return status == "" || status == INSTANCE_STATUS_TERMINATED || status == INSTANCE_STATUS_CEASED
+}
+
+// ownerLive returns true if the owner is returned by DescribeInstances and is
+// not terminated or ceased.
+func (r *gcResources) ownerLive(owner string) bool {
+	return owner != "" && !instanceGone(r.InstanceStatus[owner])
+}
+
+// keepReason returns why a resource without a live owner is only reported.
+// A terminated or ceased owner makes it an orphan, otherwise the owner may not
+// be created yet or already be purged, and the resource must be older than
+// minOrphanAge.
+func (r *gcResources) keepReason(owner string, created *time.Time) string {
+	if status := r.InstanceStatus[owner]; owner != "" && status != "" {
+		return ""
+	}
+	if created == nil {
+		return "owner unknown, create time unknown"
+	}
+	if age := r.Now.Sub(*created); age < minOrphanAge {
+		return fmt.Sprintf("owner unknown, created %s ago", humanDuration(age))
+	}
+	return ""
+}
+
+// ownerOf returns the instance a resource is named after, if any.
+func ownerOf(name *string) string {
+	if name != nil && instanceIDPattern.MatchString(*name) {
+		return *name
+	}
+	return ""
+}
+
+// ownerInstances returns IDs of all instances the resources may belong to.
+func (r *gcResources) ownerInstances() []*string {
+	seen := map[string]bool{}
+	ids := []*string{}
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, stringPtr(id))
+		}
+	}
+	for _, eip := range r.EIPs {
+		add(ownerOf(eip.EIPName))
+	}
+	for _, sg := range r.SecurityGroups {
+		add(ownerOf(sg.SecurityGroupName))
+	}
+	for _, kp := range r.KeyPairs {
+		for _, id := range kp.InstanceIDs {
+			add(*id)
+		}
+	}
+	return ids
+}
+
+func (r *gcResources) orphans() []*orphan {
+	orphans := []*orphan{}
+	for _, eip := range r.EIPs {
+		owner := ownerOf(eip.EIPName)
+		if owner == "" && !r.Tagged[*eip.EIPID] {
+			continue
+		}
+		if eip.Status == nil || *eip.Status != "available" {
+			// associated, or already being released
+			continue
+		}
+		if r.ownerLive(owner) {
+			continue
+		}
+		orphans = append(orphans, &orphan{Type: "eip", ID: *eip.EIPID, Name: stringValue(eip.EIPName), Instance: owner,
+			Keep: r.keepReason(owner, eip.CreateTime)})
+	}
+	for _, sg := range r.SecurityGroups {
+		owner := ownerOf(sg.SecurityGroupName)
+		if owner == "" && !r.Tagged[*sg.SecurityGroupID] {
+			continue
+		}
+		if (sg.IsDefault != nil && *sg.IsDefault == 1) || len(sg.Resources) > 0 {
+			continue
+		}
+		if r.ownerLive(owner) {
+			continue
+		}
+		orphans = append(orphans, &orphan{Type: "security_group", ID: *sg.SecurityGroupID, Name: stringValue(sg.SecurityGroupName), Instance: owner,
+			Keep: r.keepReason(owner, sg.CreateTime)})
+	}
+	for _, kp := range r.KeyPairs {
+		if !r.Tagged[*kp.KeyPairID] {
+			continue
+		}
+		owners := []string{}
+		live := false
+		// keypairs have no create time, one without instances may be created
+		// by a running create, and one with purged instances can't be told
+		// apart from it
+		keep := ""
+		if len(kp.InstanceIDs) == 0 {
+			keep = "no instance"
+		}
+		for _, id := range kp.InstanceIDs {
+			owners = append(owners, *id)
+			if r.ownerLive(*id) {
+				live = true
+			}
+			if r.InstanceStatus[*id] == "" {
+				keep = "owner unknown"
+			}
+		}
+		if live {
+			continue
+		}
+		orphans = append(orphans, &orphan{Type: "keypair", ID: *kp.KeyPairID, Name: stringValue(kp.KeyPairName), Instance: strings.Join(owners, ","),
+			Keep: keep})
+	}
+	for _, n := range r.Networks {
+		if n.Instances > 0 {
+			continue
+		}
+		orphans = append(orphans, &orphan{Type: "vpc", ID: n.RouterID, Name: n.Name, network: n, Keep: r.keepReason("", n.CreateTime)})
+	}
+	return orphans
+}
+
+// loadGCResources lists the resources of the zone and the status of their owning instances.
+func loadGCResources(client Client) (*gcResources, error) {
+	r := &gcResources{Tagged: map[string]bool{}, InstanceStatus: map[string]string{}, Now: time.Now()}
+	var err error
+	if r.EIPs, err = client.ListEIPs(false); err != nil {
+		return nil, err
+	}
+	if r.SecurityGroups, err = client.ListSecurityGroups(false); err != nil {
+		return nil, err
+	}
+	if r.KeyPairs, err = client.ListKeyPairs(true); err != nil {
+		return nil, err
+	}
+	for _, kp := range r.KeyPairs {
+		r.Tagged[*kp.KeyPairID] = true
+	}
+	taggedEIPs, err := client.ListEIPs(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, eip := range taggedEIPs {
+		r.Tagged[*eip.EIPID] = true
+	}
+	taggedSGs, err := client.ListSecurityGroups(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, sg := range taggedSGs {
+		r.Tagged[*sg.SecurityGroupID] = true
+	}
+	if r.Networks, err = loadVPCNetworks(client); err != nil {
+		return nil, err
+	}
+	instances, err := client.DescribeInstances(r.ownerInstances())
+	if err != nil {
+		return nil, err
+	}
+	for _, ins := range instances {
+		if ins.InstanceID != nil && ins.Status != nil {
+			r.InstanceStatus[*ins.InstanceID] = *ins.Status
+		}
+	}
+	return r, nil
+}
+
+func deleteOrphan(client Client, o *orphan) error {
+	switch o.Type {
+	case "eip":
+		return client.ReleaseEIP(&o.ID)
+	case "security_group":
+		return client.DeleteSecurityGroup(&o.ID)
+	case "keypair":
+		return client.DeleteKeyPair(&o.ID)
+	case "vpc":
+		return deleteVPCNetwork(client, o.network)
+	}
+	return fmt.Errorf("Unknown resource type [%s]", o.Type)
+}
+
+func printOrphans(w io.Writer, orphans []*orphan) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tID\tNAME\tINSTANCE\tACTION")
+	for _, o := range orphans {
+		action := "delete"
+		if o.Keep != "" {
+			action = "keep: " + o.Keep
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", o.Type, o.ID, o.Name, o.Instance, action)
+	}
+	tw.Flush()
+}
+
+func runGC(args []string) error {
+	fs, _ := newFlagSet("gc")
+	d := accountFlags(fs)
+	yes := fs.Bool("yes", false, "release and delete the orphaned resources, default is a dry run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client := d.GetClient()
+	r, err := loadGCResources(client)
+	if err != nil {
+		return err
+	}
+	orphans := r.orphans()
+	if len(orphans) == 0 {
+		fmt.Printf("No orphaned resources found in zone [%s].\n", d.Zone)
+		return nil
+	}
+	printOrphans(os.Stdout, orphans)
+	deletable := 0
+	for _, o := range orphans {
+		if o.Keep == "" {
+			deletable++
+		}
+	}
+	if !*yes {
+		fmt.Printf("\nDry run, %d orphaned resources found in zone [%s], run with --yes to delete %d of them.\n", len(orphans), d.Zone, deletable)
+		return nil
+	}
+	failed := []string{}
+	for _, o := range orphans {
+		if o.Keep != "" {
+			continue
+		}
+		if err := deleteOrphan(client, o); err != nil {
+			log.Errorf("Delete %s [%s] fail, err: [%s]", o.Type, o.ID, err.Error())
+			failed = append(failed, o.ID)
+			continue
+		}
+		log.Infof("Deleted %s [%s]", o.Type, o.ID)
+	}
+	if len(failed) > 0 {
+		return errors.New("Delete orphaned resources fail: " + strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package qingcloud
+
+import (
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestGCOrphans(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * minOrphanAge)
+	recent := now.Add(-time.Minute)
+	r := &gcResources{
+		EIPs: []*qcservice.EIP{
+			{EIPID: stringPtr("eip-gone"), EIPName: stringPtr("i-gone"), Status: stringPtr("available"), CreateTime: &recent},
+			{EIPID: stringPtr("eip-live"), EIPName: stringPtr("i-live"), Status: stringPtr("available")},
+			{EIPID: stringPtr("eip-associated"), EIPName: stringPtr("i-gone"), Status: stringPtr("associated")},
+			{EIPID: stringPtr("eip-other"), EIPName: stringPtr("web"), Status: stringPtr("available")},
+			{EIPID: stringPtr("eip-tagged"), EIPName: stringPtr("web"), Status: stringPtr("available"), CreateTime: &old},
+			{EIPID: stringPtr("eip-purged"), EIPName: stringPtr("i-purged"), Status: stringPtr("available"), CreateTime: &old},
+			{EIPID: stringPtr("eip-creating"), EIPName: stringPtr("i-new"), Status: stringPtr("available"), CreateTime: &recent},
+			{EIPID: stringPtr("eip-untimed"), EIPName: stringPtr("i-new"), Status: stringPtr("available")},
+		},
+		SecurityGroups: []*qcservice.SecurityGroup{
+			{SecurityGroupID: stringPtr("sg-gone"), SecurityGroupName: stringPtr("i-ceased")},
+			{SecurityGroupID: stringPtr("sg-used"), SecurityGroupName: stringPtr("i-ceased"), Resources: []*qcservice.Resource{{}}},
+			{SecurityGroupID: stringPtr("sg-live"), SecurityGroupName: stringPtr("i-live")},
+		},
+		KeyPairs: []*qcservice.KeyPair{
+			{KeyPairID: stringPtr("kp-gone"), InstanceIDs: []*string{stringPtr("i-gone")}},
+			{KeyPairID: stringPtr("kp-live"), InstanceIDs: []*string{stringPtr("i-gone"), stringPtr("i-live")}},
+			{KeyPairID: stringPtr("kp-unused")},
+			{KeyPairID: stringPtr("kp-purged"), InstanceIDs: []*string{stringPtr("i-purged")}},
+		},
+		Networks: []*vpcNetwork{
+			{RouterID: "rtr-empty", VxNetIDs: []string{"vxnet-empty"}, CreateTime: &old},
+			{RouterID: "rtr-used", VxNetIDs: []string{"vxnet-used"}, Instances: 2},
+			{RouterID: "rtr-new", VxNetIDs: []string{"vxnet-new"}, CreateTime: &recent},
+		},
+		Tagged: map[string]bool{"eip-tagged": true, "kp-gone": true, "kp-live": true, "kp-unused": true, "kp-purged": true},
+		InstanceStatus: map[string]string{
+			"i-gone":   INSTANCE_STATUS_TERMINATED,
+			"i-live":   INSTANCE_STATUS_RUNNING,
+			"i-ceased": INSTANCE_STATUS_CEASED,
+		},
+		Now: now,
+	}
+	// orphans to delete, and the ambiguous ones which are only reported
+	deleted := map[string]bool{
+		"eip-gone": true, "eip-tagged": true, "eip-purged": true, "sg-gone": true, "kp-gone": true, "rtr-empty": true,
+		"eip-creating": false, "eip-untimed": false, "kp-unused": false, "kp-purged": false, "rtr-new": false,
+	}
+	orphans := r.orphans()
+	for _, o := range orphans {
+		want, ok := deleted[o.ID]
+		if !ok {
+			t.Errorf("expect %s is not orphan", o.ID)
+			continue
+		}
+		if (o.Keep == "") != want {
+			t.Errorf("expect %s deleted %v, but get keep [%s]", o.ID, want, o.Keep)
+		}
+	}
+	if len(orphans) != len(deleted) {
+		t.Errorf("expect %d orphans, but get %d", len(deleted), len(orphans))
+	}
+	if owners := r.ownerInstances(); len(owners) != 5 {
+		t.Errorf("expect 5 owner instances, but get %d", len(owners))
+	}
+}
@@ -0,0 +1,289 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// maxImageCandidates limits the candidate images listed in resolve errors.
+const maxImageCandidates = 20
+
+// imageSelector selects an image by ID, or by os family, version, provider,
+// visibility and name pattern. Supported forms:
+//
+//	xenialx64b                       image ID
+//	ubuntu:16.04                     <os_family>:<version>, newest match
+//	self:my-golden-*                 <provider>:<name pattern>, newest match
+//	os_family=centos,latest          key=value filters
+//
+// Keys of the filter form are os_family, version, provider, visibility,
+// processor_type and name. Without "latest", more than one match is an error.
+type imageSelector struct {
+	ID            string
+	OSFamily      string
+	Version       string
+	Provider      string
+	Visibility    string
+	ProcessorType string
+	Name          string
+	Latest        bool
+}
+
+func parseImageSelector(s string) (*imageSelector, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("Image can not be empty.")
+	}
+	sel := &imageSelector{}
+	switch {
+	case strings.Contains(s, "="):
+		for _, item := range strings.Split(s, ",") {
+			item = strings.TrimSpace(item)
+			if item == "latest" {
+				sel.Latest = true
+				continue
+			}
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 || kv[1] == "" {
+				return nil, fmt.Errorf("Invalid image selector item [%s] in [%s].", item, s)
+			}
+			switch kv[0] {
+			case "os_family":
+				sel.OSFamily = kv[1]
+			case "version":
+				sel.Version = kv[1]
+			case "provider":
+				sel.Provider = kv[1]
+			case "visibility":
+				sel.Visibility = kv[1]
+			case "processor_type":
+				sel.ProcessorType = kv[1]
+			case "name":
+				sel.Name = kv[1]
+			default:
+				return nil, fmt.Errorf("Unknown image selector key [%s] in [%s].", kv[0], s)
+			}
+		}
+	case strings.Contains(s, ":"):
+		kv := strings.SplitN(s, ":", 2)
+		if kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("Invalid image selector [%s].", s)
+		}
+		if kv[0] == "self" || kv[0] == "system" {
+			sel.Provider = kv[0]
+			sel.Name = kv[1]
+		} else {
+			sel.OSFamily = kv[0]
+			sel.Version = kv[1]
+		}
+		sel.Latest = true
+	case strings.Contains(s, "*"):
+		sel.Name = s
+		sel.Latest = true
+	default:
+		sel.ID = s
+	}
+	if sel.Name != "" {
+		if _, err := path.Match(sel.Name, ""); err != nil {
+			return nil, fmt.Errorf("Invalid image name pattern [%s]: %s", sel.Name, err.Error())
+		}
+	}
+	return sel, nil
+}
+
+func (sel *imageSelector) filter() *ImageFilter {
+	f := &ImageFilter{
+		Provider:      sel.Provider,
+		Visibility:    sel.Visibility,
+		OSFamily:      sel.OSFamily,
+		ProcessorType: sel.ProcessorType,
+	}
+	if sel.ID != "" {
+		f.ImageIDs = []string{sel.ID}
+	}
+	return f
+}
+
+func (sel *imageSelector) match(image *qcservice.Image) bool {
+	name := ""
+	if image.ImageName != nil {
+		name = strings.ToLower(*image.ImageName)
+	}
+	if sel.Name != "" {
+		if ok, _ := path.Match(strings.ToLower(sel.Name), name); !ok {
+			return false
+		}
+	}
+	if sel.Version != "" && !strings.Contains(name, strings.ToLower(sel.Version)) {
+		return false
+	}
+	return true
+}
+
+type imagesByCreateTime []*qcservice.Image
+
+func (s imagesByCreateTime) Len() int      { return len(s) }
+func (s imagesByCreateTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s imagesByCreateTime) Less(i, j int) bool {
+	if s[i].CreateTime == nil || s[j].CreateTime == nil {
+		return s[j].CreateTime != nil
+	}
+	return s[i].CreateTime.Before(*s[j].CreateTime)
+}
+
+// selectImage picks the image matched by the selector from the candidates.
+func (sel *imageSelector) selectImage(selector string, candidates []*qcservice.Image) (*qcservice.Image, error) {
+	matches := []*qcservice.Image{}
+	for _, image := range candidates {
+		if sel.match(image) {
+			matches = append(matches, image)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) > 1 && sel.Latest:
+		sort.Sort(imagesByCreateTime(matches))
+		return matches[len(matches)-1], nil
+	case len(matches) > 1:
+		return nil, fmt.Errorf("Image [%s] matches more than one image, add \"latest\" to select the newest one, candidates: %s",
+			selector, describeImages(matches))
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("Image [%s] not found.", selector)
+	}
+	return nil, fmt.Errorf("Image [%s] not found, candidates: %s", selector, describeImages(candidates))
+}
+
+func describeImages(images []*qcservice.Image) string {
+	items := []string{}
+	for i, image := range images {
+		if i == maxImageCandidates {
+			items = append(items, fmt.Sprintf("... (%d more)", len(images)-maxImageCandidates))
+			break
+		}
+		name := ""
+		if image.ImageName != nil {
+			name = *image.ImageName
+		}
+		items = append(items, fmt.Sprintf("%s (%s)", *image.ImageID, name))
+	}
+	return strings.Join(items, ", ")
+}
+
+// resolveImage resolves the image selector of the driver to an image ID.
+func (d *Driver) resolveImage() error {
+	selector := d.Image
+	if d.ImageSelector != "" {
+		selector = d.ImageSelector
+	}
+	sel, err := parseImageSelector(selector)
+	if err != nil {
+		return err
+	}
+	client := d.GetClient()
+	candidates, err := client.DescribeImages(sel.filter())
+	if err != nil {
+		return err
+	}
+	if sel.ID != "" && len(candidates) == 0 {
+		// not an ID, fall back to match the image name
+		sel = &imageSelector{Name: sel.ID}
+		candidates, err = client.DescribeImages(sel.filter())
+		if err != nil {
+			return err
+		}
+	}
+	image, err := sel.selectImage(selector, candidates)
+	if err != nil {
+		return err
+	}
+	d.ImageSelector = selector
+	d.Image = *image.ImageID
+	log.Infof("Using image [%s] for [%s]", d.Image, selector)
+	return nil
+}
+
+// Capture stops the instance and captures it as a new image, returns the image ID.
+func (d *Driver) Capture(imageName string) (string, error) {
+	client := d.GetClient()
+	ins, err := d.getInstance()
+	if err != nil {
+		return "", err
+	}
+	if ins.Status != nil && *ins.Status != INSTANCE_STATUS_STOPPED {
+		log.Infof("Stopping Instance [%s] before capture...", *d.InstanceID)
+		if err := d.Stop(); err != nil {
+			return "", err
+		}
+	}
+	if imageName == "" {
+		imageName = fmt.Sprintf("%s-%s", *d.InstanceID, time.Now().Format("20060102150405"))
+	}
+	log.Infof("Capturing Instance [%s] as image [%s]...", *d.InstanceID, imageName)
+	imageID, err := client.CaptureInstance(d.InstanceID, &imageName)
+	if err != nil {
+		return "", err
+	}
+	d.tagResource("image", imageID)
+	return *imageID, nil
+}
+
+// ShareImage grants the image to other QingCloud user accounts.
+func (d *Driver) ShareImage(imageID string, users []string) error {
+	if len(users) == 0 {
+		return errors.New("Users to share image with can not be empty.")
+	}
+	userIDs := make([]*string, 0, len(users))
+	for _, u := range users {
+		userIDs = append(userIDs, stringPtr(u))
+	}
+	return d.GetClient().GrantImageToUsers(&imageID, userIDs)
+}
+
+func runCapture(args []string) error {
+	fs, store := newFlagSet("capture")
+	imageName := fs.String("image-name", "", "name of the new image, default is <instance-id>-<timestamp>")
+	shareWith := fs.String("share-with", "", "comma separated QingCloud user ids to grant the image to")
+	start := fs.Bool("start", false, "start the machine again after capture")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	_, d, err := store.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	imageID, err := d.Capture(*imageName)
+	if err != nil {
+		return err
+	}
+	// the image is captured, it is printed and the machine started even if
+	// sharing fails
+	fmt.Println(imageID)
+	errs := multiError{}
+	if *shareWith != "" {
+		users := strings.Split(*shareWith, ",")
+		if err := d.ShareImage(imageID, users); err != nil {
+			errs = append(errs, fmt.Errorf("Grant image [%s] to users %v fail, err: [%s]", imageID, users, err.Error()))
+		} else {
+			log.Infof("Granted image [%s] to users %v", imageID, users)
+		}
+	}
+	if *start {
+		if err := d.Start(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errorOrNil()
+}
@@ -0,0 +1,72 @@
+package qingcloud
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestParseImageSelector(t *testing.T) {
+	cases := []struct {
+		selector string
+		expect   imageSelector
+	}{
+		{"xenialx64b", imageSelector{ID: "xenialx64b"}},
+		{"ubuntu:16.04", imageSelector{OSFamily: "ubuntu", Version: "16.04", Latest: true}},
+		{"self:my-golden-*", imageSelector{Provider: "self", Name: "my-golden-*", Latest: true}},
+		{"os_family=centos,latest", imageSelector{OSFamily: "centos", Latest: true}},
+		{"provider=system,visibility=public,name=CentOS*", imageSelector{Provider: "system", Visibility: "public", Name: "CentOS*"}},
+	}
+	for _, c := range cases {
+		sel, err := parseImageSelector(c.selector)
+		if err != nil {
+			t.Errorf("parse [%s] error: %s", c.selector, err.Error())
+			continue
+		}
+		if *sel != c.expect {
+			t.Errorf("parse [%s] expect %+v, but get %+v", c.selector, c.expect, *sel)
+		}
+	}
+	for _, selector := range []string{"", "os_family=", "foo=bar", ":16.04", "name=[a"} {
+		if _, err := parseImageSelector(selector); err == nil {
+			t.Errorf("expect error when parse [%s]", selector)
+		}
+	}
+}
+
+func testImage(id, name string, created time.Time) *qcservice.Image {
+	return &qcservice.Image{ImageID: stringPtr(id), ImageName: stringPtr(name), CreateTime: &created}
+}
+
+func TestSelectImage(t *testing.T) {
+	now := time.Now()
+	images := []*qcservice.Image{
+		testImage("xenialx64a", "Ubuntu Server 16.04.1 LTS 64bit", now.Add(-time.Hour)),
+		testImage("xenialx64b", "Ubuntu Server 16.04.3 LTS 64bit", now),
+		testImage("trustysrvx64h", "Ubuntu Server 14.04.5 LTS 64bit", now.Add(-2*time.Hour)),
+	}
+	sel, _ := parseImageSelector("ubuntu:16.04")
+	image, err := sel.selectImage("ubuntu:16.04", images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *image.ImageID != "xenialx64b" {
+		t.Errorf("expect newest image xenialx64b, but get %s", *image.ImageID)
+	}
+
+	sel, _ = parseImageSelector("name=Ubuntu Server 16.04*")
+	if _, err := sel.selectImage("name=Ubuntu Server 16.04*", images); err == nil {
+		t.Error("expect error when more than one image matches without latest")
+	}
+
+	sel, _ = parseImageSelector("ubuntu:18.04")
+	_, err = sel.selectImage("ubuntu:18.04", images)
+	if err == nil {
+		t.Fatal("expect error when no image matches")
+	}
+	if !strings.Contains(err.Error(), "trustysrvx64h") {
+		t.Errorf("expect candidates in error, but get %s", err.Error())
+	}
+}
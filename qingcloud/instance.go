@@ -0,0 +1,243 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	qcerrors "github.com/yunify/qingcloud-sdk-go/request/errors"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// retCodeResourceNotFound is returned for a resource ID which does not exist.
+const retCodeResourceNotFound = 2100
+
+// instanceNotFoundError is returned by DescribeInstance when the instance does
+// not exist any more.
+type instanceNotFoundError struct {
+	InstanceID string
+}
+
+func (e *instanceNotFoundError) Error() string {
+	return fmt.Sprintf("Instance with id [%s] not exist.", e.InstanceID)
+}
+
+// resourceNotFoundError is returned by the describe methods of other resources
+// when the describe set is empty.
+type resourceNotFoundError struct {
+	Type string
+	ID   string
+}
+
+func (e *resourceNotFoundError) Error() string {
+	return fmt.Sprintf("%s with id [%s] not exist.", e.Type, e.ID)
+}
+
+// waitTimeoutError is returned when the instance doesn't reach the status in
+// time.
+type waitTimeoutError struct {
+	InstanceID string
+	Status     string
+	Timeout    int
+}
+
+func (e *waitTimeoutError) Error() string {
+	return fmt.Sprintf("Instance [%s] did not become [%s] in %d seconds.", e.InstanceID, e.Status, e.Timeout)
+}
+
+// isWaitTimeoutError returns true if err means the wait for a status timed out.
+func isWaitTimeoutError(err error) bool {
+	_, ok := err.(*waitTimeoutError)
+	return ok
+}
+
+// isNotFoundError returns true if err means the resource does not exist.
+func isNotFoundError(err error) bool {
+	switch e := err.(type) {
+	case *instanceNotFoundError, *resourceNotFoundError:
+		return true
+	case *qcerrors.QingCloudError:
+		return e.RetCode == retCodeResourceNotFound
+	}
+	return false
+}
+
+// instanceState maps the status of the instance, and its transition status
+// while an operation is running, to a machine state. Suspended, terminated and
+// ceased instances come with an error explaining the state.
+func instanceState(ins *qcservice.Instance) (state.State, error) {
+	switch stringValue(ins.TransitionStatus) {
+	case "creating", "starting", "restarting", "resuming", "recovering", "resetting":
+		return state.Starting, nil
+	case "stopping", "suspending", "terminating":
+		return state.Stopping, nil
+	}
+	if ins.Status == nil {
+		return state.None, nil
+	}
+	switch *ins.Status {
+	case INSTANCE_STATUS_PENDING:
+		return state.Starting, nil
+	case INSTANCE_STATUS_RUNNING:
+		return state.Running, nil
+	case INSTANCE_STATUS_STOPPED:
+		return state.Stopped, nil
+	case INSTANCE_STATUS_SUSPENDED:
+		return state.Error, fmt.Errorf("Instance [%s] is suspended, usually because the account is in arrears, it is resumed after the account is recharged.",
+			stringValue(ins.InstanceID))
+	case INSTANCE_STATUS_TERMINATED, INSTANCE_STATUS_CEASED:
+		return state.None, fmt.Errorf("Instance [%s] is %s, the machine is gone, remove it with docker-machine rm.",
+			stringValue(ins.InstanceID), *ins.Status)
+	}
+	return state.Error, fmt.Errorf("Unknown status [%s] of Instance [%s].", *ins.Status, stringValue(ins.InstanceID))
+}
+
+// checkInstanceType returns an error if the zone doesn't offer the cpu and memory combination.
+func checkInstanceType(types []*qcservice.InstanceType, cpu int, memory int) error {
+	if len(types) == 0 {
+		log.Warnf("No instance types returned, skip checking cpu [%d] memory [%d]", cpu, memory)
+		return nil
+	}
+	offered := []string{}
+	for _, t := range types {
+		if t.VCPUsCurrent == nil || t.MemoryCurrent == nil {
+			continue
+		}
+		if *t.VCPUsCurrent == cpu && *t.MemoryCurrent == memory {
+			return nil
+		}
+		offered = append(offered, fmt.Sprintf("%d/%d", *t.VCPUsCurrent, *t.MemoryCurrent))
+	}
+	return fmt.Errorf("CPU [%d] memory [%d] is not offered in this zone, offered cpu/memory: %s",
+		cpu, memory, strings.Join(offered, ", "))
+}
+
+// Resize changes the cpu and memory of the instance. A running instance is
+// stopped before and started again after resize.
+func (d *Driver) Resize(cpu int, memory int) error {
+	if cpu <= 0 || memory <= 0 {
+		return errors.New("CPU and memory must be > 0")
+	}
+	if cpu == d.CPU && memory == d.Memory {
+		log.Infof("Instance [%s] already has cpu [%d] memory [%d]", *d.InstanceID, cpu, memory)
+		return nil
+	}
+	client := d.GetClient()
+	types, err := client.DescribeInstanceTypes()
+	if err != nil {
+		return err
+	}
+	if err := checkInstanceType(types, cpu, memory); err != nil {
+		return err
+	}
+	ins, err := d.getInstance()
+	if err != nil {
+		return err
+	}
+	running := ins.Status != nil && *ins.Status == INSTANCE_STATUS_RUNNING
+	if running {
+		log.Infof("Stopping Instance [%s] before resize...", *d.InstanceID)
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+	log.Infof("Resizing Instance [%s] to cpu [%d] memory [%d]...", *d.InstanceID, cpu, memory)
+	if err := client.ResizeInstance(d.InstanceID, cpu, memory); err != nil {
+		return err
+	}
+	d.CPU = cpu
+	d.Memory = memory
+	if running {
+		log.Infof("Starting Instance [%s]...", *d.InstanceID)
+		return d.Start()
+	}
+	return nil
+}
+
+func runResize(args []string) error {
+	fs, store := newFlagSet("resize")
+	cpu := fs.Int("cpu", 0, "new cpu count")
+	memory := fs.Int("memory", 0, "new memory size in MB")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	h, d, err := store.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *cpu == 0 {
+		*cpu = d.CPU
+	}
+	if *memory == 0 {
+		*memory = d.Memory
+	}
+	resizeErr := d.Resize(*cpu, *memory)
+	// save even on failure, the instance may have been resized before start failed
+	if err := store.Save(h, d); err != nil {
+		return err
+	}
+	return resizeErr
+}
+
+// Reset reinstalls the image on the instance. The EIP, security group and
+// keypair bindings are kept, so the machine can be provisioned again with the
+// same certificates.
+func (d *Driver) Reset() error {
+	client := d.GetClient()
+	ins, err := d.getInstance()
+	if err != nil {
+		return err
+	}
+	if ins.Status != nil && *ins.Status == INSTANCE_STATUS_RUNNING {
+		log.Infof("Stopping Instance [%s] before reset...", *d.InstanceID)
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+	log.Infof("Resetting Instance [%s]...", *d.InstanceID)
+	if err := client.ResetInstance(d.InstanceID, &d.LoginKeyPair); err != nil {
+		return err
+	}
+	ins, err = d.getInstance()
+	if err != nil {
+		return err
+	}
+	if ins.Status != nil && *ins.Status == INSTANCE_STATUS_RUNNING {
+		err = client.WaitInstanceStatus(d.InstanceID, INSTANCE_STATUS_RUNNING)
+	} else {
+		err = d.Start()
+	}
+	if err != nil {
+		return err
+	}
+	return resetOSEnvCheck(d)
+}
+
+// resetOSEnvCheck checks the reinstalled system over ssh, replaced in tests.
+var resetOSEnvCheck = (*Driver).checkOSEnv
+
+func runReset(args []string) error {
+	fs, store := newFlagSet("reset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	_, d, err := store.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := d.Reset(); err != nil {
+		return err
+	}
+	fmt.Printf("Instance [%s] is reset, run \"docker-machine provision %s\" to reinstall docker.\n", *d.InstanceID, fs.Arg(0))
+	return nil
+}
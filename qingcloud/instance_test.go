@@ -0,0 +1,234 @@
+package qingcloud
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/state"
+	qcerrors "github.com/yunify/qingcloud-sdk-go/request/errors"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestCheckInstanceType(t *testing.T) {
+	types := []*qcservice.InstanceType{
+		{VCPUsCurrent: intPtr(1), MemoryCurrent: intPtr(1024)},
+		{VCPUsCurrent: intPtr(2), MemoryCurrent: intPtr(4096)},
+	}
+	if err := checkInstanceType(types, 2, 4096); err != nil {
+		t.Error(err)
+	}
+	if err := checkInstanceType(types, 2, 1024); err == nil {
+		t.Error("expect error when cpu/memory combination not offered")
+	}
+	if err := checkInstanceType(nil, 2, 1024); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInstanceState(t *testing.T) {
+	cases := []struct {
+		status     string
+		transition string
+		state      state.State
+		err        bool
+	}{
+		{INSTANCE_STATUS_PENDING, "creating", state.Starting, false},
+		{INSTANCE_STATUS_RUNNING, "", state.Running, false},
+		{INSTANCE_STATUS_RUNNING, "stopping", state.Stopping, false},
+		{INSTANCE_STATUS_RUNNING, "restarting", state.Starting, false},
+		{INSTANCE_STATUS_STOPPED, "", state.Stopped, false},
+		{INSTANCE_STATUS_STOPPED, "starting", state.Starting, false},
+		{INSTANCE_STATUS_SUSPENDED, "", state.Error, true},
+		{INSTANCE_STATUS_TERMINATED, "", state.None, true},
+		{INSTANCE_STATUS_CEASED, "", state.None, true},
+	}
+	for _, c := range cases {
+		ins := &qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(c.status), TransitionStatus: stringPtr(c.transition)}
+		s, err := instanceState(ins)
+		if s != c.state || (err != nil) != c.err {
+			t.Errorf("expect state [%s] error %v of %s/%s, but get [%s] %v", c.state, c.err, c.status, c.transition, s, err)
+		}
+	}
+}
+
+func TestIsNotFoundError(t *testing.T) {
+	if !isNotFoundError(&instanceNotFoundError{InstanceID: "i-test"}) {
+		t.Error("expect instance not found error")
+	}
+	if !isNotFoundError(&qcerrors.QingCloudError{RetCode: retCodeResourceNotFound}) {
+		t.Error("expect resource not found error")
+	}
+	if isNotFoundError(&qcerrors.QingCloudError{RetCode: retCodeQuotaExceeded}) {
+		t.Error("expect quota error is not a not found error")
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := newFakeClient(&qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(INSTANCE_STATUS_RUNNING)})
+	d := newFakeDriver(c, "i-test")
+	d.LoginKeyPair = "kp-test"
+	d.KeyPairCreated = true
+	d.EIP = &qcservice.EIP{EIPID: stringPtr("eip-test"), EIPAddr: stringPtr("1.2.3.4")}
+	d.SecurityGroup = &qcservice.SecurityGroup{SecurityGroupID: stringPtr("sg-test")}
+	d.IPAddress = "1.2.3.4"
+	checked := -1
+	defer func(check func(*Driver) error) { resetOSEnvCheck = check }(resetOSEnvCheck)
+	resetOSEnvCheck = func(*Driver) error {
+		checked = len(c.calls)
+		return nil
+	}
+	if err := d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	reset, wait := c.index("ResetInstance"), c.index("WaitInstanceStatus")
+	if !c.called("ResetInstance i-test kp-test") || !c.called("WaitInstanceStatus i-test running") || reset > wait {
+		t.Errorf("expect reset with the login keypair and wait for running, but get %v", c.calls)
+	}
+	if checked < wait+1 {
+		t.Errorf("expect os env checked after the instance is running, but get %v", c.calls)
+	}
+	if stringValue(d.EIP.EIPID) != "eip-test" || stringValue(d.SecurityGroup.SecurityGroupID) != "sg-test" ||
+		d.LoginKeyPair != "kp-test" || !d.KeyPairCreated || d.IPAddress != "1.2.3.4" {
+		t.Errorf("expect EIP, security group and keypair kept, but get %+v", d)
+	}
+	for _, method := range []string{"ReleaseEIP", "DeleteSecurityGroup", "DeleteKeyPair", "TerminateInstance"} {
+		if c.index(method) >= 0 {
+			t.Errorf("expect no %s on reset, but get %v", method, c.calls)
+		}
+	}
+}
+
+func TestStopTimeoutForcesStop(t *testing.T) {
+	c := newFakeClient(&qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(INSTANCE_STATUS_RUNNING)})
+	c.errs["StopInstanceWithin"] = &waitTimeoutError{InstanceID: "i-test", Status: INSTANCE_STATUS_STOPPED, Timeout: 60}
+	d := newFakeDriver(c, "i-test")
+	d.StopTimeout = 60
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.called("StopInstanceWithin i-test 60") || !c.called("StopInstance i-test true") {
+		t.Errorf("expect a graceful stop then a forced stop, but get %v", c.calls)
+	}
+}
+
+func TestStopErrorIsReturned(t *testing.T) {
+	c := newFakeClient(&qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(INSTANCE_STATUS_RUNNING)})
+	stopErr := &qcerrors.QingCloudError{RetCode: 1400, Message: "permission denied"}
+	c.errs["StopInstanceWithin"] = stopErr
+	d := newFakeDriver(c, "i-test")
+	if err := d.Stop(); err != stopErr {
+		t.Errorf("expect the stop error returned, but get %v", err)
+	}
+	if c.index("StopInstance") >= 0 {
+		t.Errorf("expect no forced stop, but get %v", c.calls)
+	}
+}
+
+// newRemoveDriver returns a driver of i-test with resources to clean up on
+// remove, like one loaded from the store.
+func newRemoveDriver(c *fakeClient) *Driver {
+	d := newFakeDriver(c, "i-test")
+	d.EIP = &qcservice.EIP{EIPID: stringPtr("eip-test")}
+	d.SecurityGroup = &qcservice.SecurityGroup{SecurityGroupID: stringPtr("sg-test")}
+	d.LoginKeyPair = "kp-test"
+	d.KeyPairCreated = true
+	d.ExtraKeyPairs = []string{"kp-extra"}
+	d.DNSAlias = "dns-test"
+	d.Nics = []string{"52:54:00:00:00:01"}
+	d.LoadBalancer = "lb-test"
+	d.LBBackends = []string{"lbb-test"}
+	return d
+}
+
+func newRemoveClient(status string) *fakeClient {
+	c := newFakeClient()
+	if status != "" {
+		c.instances["i-test"] = &qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(status)}
+	}
+	c.lbs["lb-test"] = &qcservice.LoadBalancer{
+		LoadBalancerID:   stringPtr("lb-test"),
+		LoadBalancerName: stringPtr("swarm"),
+		EIPs:             []*qcservice.EIP{{EIPID: stringPtr("eip-lb"), EIPName: stringPtr("swarm")}},
+	}
+	c.backends["lb-test"] = []*qcservice.LoadBalancerBackend{{LoadBalancerBackendID: stringPtr("lbb-test")}}
+	return c
+}
+
+func TestRemoveLiveInstance(t *testing.T) {
+	c := newRemoveClient(INSTANCE_STATUS_RUNNING)
+	if err := newRemoveDriver(c).Remove(); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range []string{
+		"DetachKeyPairs i-test kp-extra", "DeleteLoadBalancerBackends lbb-test", "DeleteLoadBalancer lb-test", "ReleaseEIP eip-lb",
+		"DissociateDNSAlias dns-test", "DeleteNics 52:54:00:00:00:01", "TerminateInstance i-test",
+		"ReleaseEIP eip-test", "DeleteSecurityGroup sg-test", "DeleteKeyPair kp-test",
+	} {
+		if !c.called(call) {
+			t.Errorf("expect %s, but get calls %v", call, c.calls)
+		}
+	}
+	terminate := c.index("TerminateInstance")
+	if c.index("DetachKeyPairs") > terminate || c.index("DeleteSecurityGroup") < terminate {
+		t.Errorf("expect cleanup, terminate, then release, but get calls %v", c.calls)
+	}
+}
+
+func TestRemoveGoneInstance(t *testing.T) {
+	c := newRemoveClient("")
+	// the alias was dissociated by an earlier remove
+	c.deleted["dns-test"] = true
+	if err := newRemoveDriver(c).Remove(); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range []string{"DeleteNics 52:54:00:00:00:01", "ReleaseEIP eip-test", "DeleteSecurityGroup sg-test", "DeleteKeyPair kp-test"} {
+		if !c.called(call) {
+			t.Errorf("expect %s, but get calls %v", call, c.calls)
+		}
+	}
+	for _, method := range []string{"TerminateInstance", "DetachKeyPairs"} {
+		if c.index(method) >= 0 {
+			t.Errorf("expect no %s for a gone instance, but get calls %v", method, c.calls)
+		}
+	}
+}
+
+func TestRemoveRetryAfterPartialFailure(t *testing.T) {
+	c := newRemoveClient(INSTANCE_STATUS_RUNNING)
+	c.errs["DeleteSecurityGroup"] = errors.New("security group is busy")
+	if err := newRemoveDriver(c).Remove(); err == nil {
+		t.Fatal("expect error when the security group is not deleted")
+	}
+	if !c.called("TerminateInstance i-test") || !c.called("ReleaseEIP eip-test") || !c.called("DeleteKeyPair kp-test") {
+		t.Errorf("expect the other resources released, but get calls %v", c.calls)
+	}
+	// docker-machine rm again loads the machine as it was stored
+	delete(c.errs, "DeleteSecurityGroup")
+	c.calls = nil
+	if err := newRemoveDriver(c).Remove(); err != nil {
+		t.Fatalf("expect released resources are skipped on retry, but get %s", err)
+	}
+	if !c.called("DeleteSecurityGroup sg-test") || c.index("TerminateInstance") >= 0 {
+		t.Errorf("expect only the security group deleted on retry, but get calls %v", c.calls)
+	}
+}
+
+func TestRemoveWithoutInstance(t *testing.T) {
+	c := newFakeClient()
+	d := newFakeDriver(c, "")
+	d.EIP = &qcservice.EIP{EIPID: stringPtr("eip-test")}
+	d.SecurityGroup = &qcservice.SecurityGroup{SecurityGroupID: stringPtr("sg-test")}
+	d.LoginKeyPair = "kp-test"
+	d.KeyPairCreated = true
+	if err := d.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if c.index("DescribeInstance") >= 0 || c.index("TerminateInstance") >= 0 {
+		t.Errorf("expect no instance steps, but get calls %v", c.calls)
+	}
+	for _, call := range []string{"ReleaseEIP eip-test", "DeleteSecurityGroup sg-test", "DeleteKeyPair kp-test"} {
+		if !c.called(call) {
+			t.Errorf("expect %s, but get calls %v", call, c.calls)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package qingcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// inventoryItem is a driver managed instance listed by the inventory command.
+type inventoryItem struct {
+	Name       string     `json:"name"`
+	InstanceID string     `json:"instance_id"`
+	Zone       string     `json:"zone"`
+	KeyPairs   []string   `json:"keypairs"`
+	IP         string     `json:"ip"`
+	EIP        string     `json:"eip"`
+	Status     string     `json:"status"`
+	CreateTime *time.Time `json:"create_time"`
+	Machine    string     `json:"machine"`
+}
+
+func newInventoryItem(zone string, ins *qcservice.Instance, machines map[string]string) *inventoryItem {
+	item := &inventoryItem{
+		Name:       stringValue(ins.InstanceName),
+		InstanceID: *ins.InstanceID,
+		Zone:       zone,
+		KeyPairs:   []string{},
+		Status:     stringValue(ins.Status),
+		CreateTime: ins.CreateTime,
+		Machine:    machines[*ins.InstanceID],
+	}
+	for _, id := range ins.KeyPairIDs {
+		item.KeyPairs = append(item.KeyPairs, *id)
+	}
+	if len(ins.VxNets) > 0 {
+		item.IP = stringValue(ins.VxNets[0].PrivateIP)
+	}
+	if ins.EIP != nil {
+		item.EIP = stringValue(ins.EIP.EIPAddr)
+	}
+	return item
+}
+
+// humanDuration formats a duration for people, e.g. "3 days".
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days", int(d.Hours()/24))
+}
+
+// machinesByInstance maps the instance IDs of the qingcloud machines in the
+// store to the machine names.
+func (s *machineStore) machinesByInstance() map[string]string {
+	machines := map[string]string{}
+	names, err := s.filestore().List()
+	if err != nil {
+		log.Warnf("List machines of store [%s] fail, err: [%s]", s.path, err.Error())
+		return machines
+	}
+	for _, name := range names {
+		_, d, err := s.Load(name)
+		if err != nil {
+			log.Debugf("Skip machine [%s]: %s", name, err.Error())
+			continue
+		}
+		machines[*d.InstanceID] = name
+	}
+	return machines
+}
+
+// inventory lists the driver managed instances of the zones.
+func (d *Driver) inventory(zones []string, machines map[string]string) ([]*inventoryItem, error) {
+	items := []*inventoryItem{}
+	for _, zone := range zones {
+		zd := d.zoneDriver(zone)
+		instances, err := zd.GetClient().ListInstances(true)
+		if err != nil {
+			return nil, fmt.Errorf("List instances of zone [%s] error: [%s]", zone, err.Error())
+		}
+		for _, ins := range instances {
+			items = append(items, newInventoryItem(zone, ins, machines))
+		}
+	}
+	return items, nil
+}
+
+func printInventory(w io.Writer, items []*inventoryItem) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tINSTANCE\tZONE\tKEYPAIRS\tIP\tEIP\tSTATUS\tAGE\tMACHINE")
+	for _, item := range items {
+		age := ""
+		if item.CreateTime != nil {
+			age = humanDuration(time.Since(*item.CreateTime))
+		}
+		machine := item.Machine
+		if machine == "" {
+			machine = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", item.Name, item.InstanceID, item.Zone,
+			strings.Join(item.KeyPairs, ","), item.IP, item.EIP, item.Status, age, machine)
+	}
+	tw.Flush()
+}
+
+func runInventory(args []string) error {
+	fs, store := newFlagSet("inventory")
+	d := accountFlags(fs)
+	listZones := zonesFlag(fs, d, "list")
+	format := fs.String("format", "table", "output format, table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("Unknown format [%s].", *format)
+	}
+	zones, err := listZones()
+	if err != nil {
+		return err
+	}
+	items, err := d.inventory(zones, store.machinesByInstance())
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	printInventory(os.Stdout, items)
+	return nil
+}
@@ -0,0 +1,36 @@
+package qingcloud
+
+import (
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestNewInventoryItem(t *testing.T) {
+	ins := &qcservice.Instance{
+		InstanceID:   stringPtr("i-abcdefgh"),
+		InstanceName: stringPtr("ci-host"),
+		Status:       stringPtr(INSTANCE_STATUS_RUNNING),
+		KeyPairIDs:   []*string{stringPtr("kp-1"), stringPtr("kp-2")},
+		VxNets:       []*qcservice.VxNet{{PrivateIP: stringPtr("10.0.0.2")}},
+		EIP:          &qcservice.EIP{EIPAddr: stringPtr("1.2.3.4")},
+	}
+	item := newInventoryItem("pek3a", ins, map[string]string{"i-abcdefgh": "ci"})
+	if item.Machine != "ci" || item.IP != "10.0.0.2" || item.EIP != "1.2.3.4" || len(item.KeyPairs) != 2 {
+		t.Errorf("unexpected inventory item %+v", item)
+	}
+	item = newInventoryItem("pek3a", ins, map[string]string{})
+	if item.Machine != "" {
+		t.Errorf("expect no machine, but get %s", item.Machine)
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	if s := humanDuration(3 * time.Hour); s != "3 hours" {
+		t.Errorf("expect 3 hours, but get %s", s)
+	}
+	if s := humanDuration(72 * time.Hour); s != "3 days" {
+		t.Errorf("expect 3 days, but get %s", s)
+	}
+}
@@ -0,0 +1,149 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// extraKeyPairIDs returns the extra keypairs, skipping the login keypair.
+func (d *Driver) extraKeyPairIDs() []*string {
+	ids := []*string{}
+	for _, id := range d.ExtraKeyPairs {
+		if id != "" && id != d.LoginKeyPair {
+			ids = append(ids, stringPtr(id))
+		}
+	}
+	return ids
+}
+
+// attachExtraKeyPairs attaches the qingcloud-extra-keypair keypairs to the instance.
+func (d *Driver) attachExtraKeyPairs() error {
+	ids := d.extraKeyPairIDs()
+	if len(ids) == 0 {
+		return nil
+	}
+	log.Infof("Attaching KeyPairs %v to Instance [%s]...", d.ExtraKeyPairs, *d.InstanceID)
+	return d.GetClient().AttachKeyPairs(d.InstanceID, ids)
+}
+
+// detachExtraKeyPairs detaches the extra keypairs from the instance, the
+// keypairs are shared and never deleted.
+func (d *Driver) detachExtraKeyPairs() error {
+	ids := d.extraKeyPairIDs()
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := d.GetClient().DetachKeyPairs(d.InstanceID, ids); err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("Detach KeyPairs %v from Instance [%s] fail, err: [%s]", d.ExtraKeyPairs, *d.InstanceID, err.Error())
+	}
+	return nil
+}
+
+// RotateKeyPair replaces the login keypair of the instance with a new key
+// generated at keyPath, without recreating the instance. The old keypair is
+// detached, and deleted if the driver created it. Once the new keypair works
+// the rotation is not rolled back, a failed detach of the old one is logged.
+func (d *Driver) RotateKeyPair(keyPath string) error {
+	client := d.GetClient()
+	oldKeyPair, oldKeyPath, oldCreated := d.LoginKeyPair, d.SSHKeyPath, d.KeyPairCreated
+
+	if err := ssh.GenerateSSHKey(keyPath); err != nil {
+		return err
+	}
+	d.SSHKeyPath = keyPath
+	publicKey, err := d.readPublicKey()
+	if err != nil {
+		return err
+	}
+	keyPairID, err := client.CreateKeyPair(stringPtr(d.MachineName), &publicKey)
+	if err != nil {
+		d.SSHKeyPath = oldKeyPath
+		return err
+	}
+	d.tagResource("keypair", keyPairID)
+	log.Infof("Attaching KeyPair [%s] to Instance [%s]...", *keyPairID, *d.InstanceID)
+	if err := client.AttachKeyPairs(d.InstanceID, []*string{keyPairID}); err != nil {
+		d.SSHKeyPath = oldKeyPath
+		if err := client.DeleteKeyPair(keyPairID); err != nil {
+			log.Errorf("Delete KeyPair [%s] fail, err: [%s]", *keyPairID, err.Error())
+		}
+		return err
+	}
+	d.LoginKeyPair = *keyPairID
+	d.KeyPairCreated = true
+
+	if err := d.checkSSH(); err != nil {
+		log.Errorf("SSH to Instance [%s] with new key fail, rollback to KeyPair [%s]", *d.InstanceID, oldKeyPair)
+		if err := client.DetachKeyPairs(d.InstanceID, []*string{keyPairID}); err != nil {
+			log.Errorf("Detach KeyPair [%s] fail, err: [%s]", *keyPairID, err.Error())
+		} else if err := client.DeleteKeyPair(keyPairID); err != nil {
+			log.Errorf("Delete KeyPair [%s] fail, err: [%s]", *keyPairID, err.Error())
+		}
+		d.LoginKeyPair, d.SSHKeyPath, d.KeyPairCreated = oldKeyPair, oldKeyPath, oldCreated
+		return err
+	}
+
+	if oldKeyPair != "" {
+		log.Infof("Detaching KeyPair [%s] from Instance [%s]...", oldKeyPair, *d.InstanceID)
+		if err := client.DetachKeyPairs(d.InstanceID, []*string{&oldKeyPair}); err != nil {
+			// the new keypair works, so the rotation is kept and saved
+			log.Warnf("Detach KeyPair [%s] from Instance [%s] fail, detach it in the console, err: [%s]", oldKeyPair, *d.InstanceID, err.Error())
+			return nil
+		}
+		if oldCreated {
+			if err := client.DeleteKeyPair(&oldKeyPair); err != nil {
+				log.Errorf("Delete KeyPair [%s] fail, err: [%s]", oldKeyPair, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// checkSSH returns an error if the instance can not be reached with the
+// current ssh key.
+func (d *Driver) checkSSH() error {
+	sshClient, err := drivers.GetSSHClientFromDriver(d)
+	if err != nil {
+		return err
+	}
+	_, err = sshClient.Output("true")
+	return err
+}
+
+func runRotateKey(args []string) error {
+	fs, store := newFlagSet("rotate-key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	name := fs.Arg(0)
+	h, d, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+	oldKeyPath := d.SSHKeyPath
+	keyPath := filepath.Join(store.filestore().GetMachinesDir(), name, fmt.Sprintf("id_rsa-%d", time.Now().Unix()))
+	if err := d.RotateKeyPair(keyPath); err != nil {
+		return err
+	}
+	if err := store.Save(h, d); err != nil {
+		return err
+	}
+	// the old key is only removed when the driver generated it in the machine dir
+	if oldKeyPath != "" && filepath.Dir(oldKeyPath) == filepath.Dir(keyPath) {
+		os.Remove(oldKeyPath)
+		os.Remove(oldKeyPath + ".pub")
+	}
+	fmt.Printf("Instance [%s] login keypair rotated to [%s], ssh key [%s].\n", *d.InstanceID, d.LoginKeyPair, d.SSHKeyPath)
+	return nil
+}
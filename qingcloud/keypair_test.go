@@ -0,0 +1,15 @@
+package qingcloud
+
+import (
+	"testing"
+)
+
+func TestExtraKeyPairIDs(t *testing.T) {
+	d := NewDriver("test", "")
+	d.LoginKeyPair = "kp-login"
+	d.ExtraKeyPairs = []string{"kp-a", "", "kp-login", "kp-b"}
+	ids := d.extraKeyPairIDs()
+	if len(ids) != 2 || *ids[0] != "kp-a" || *ids[1] != "kp-b" {
+		t.Errorf("expect [kp-a kp-b], got %v", d.ExtraKeyPairs)
+	}
+}
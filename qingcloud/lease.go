@@ -0,0 +1,266 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// The lease of a machine is kept in the instance description as
+// expires=<RFC3339 time>, so it is visible in the console and survives the
+// local store.
+var expiryPattern = regexp.MustCompile(`expires=(\S+)`)
+
+// parseExpiry returns the expiry recorded in the description, if any.
+func parseExpiry(description string) (time.Time, bool) {
+	m := expiryPattern.FindStringSubmatch(description)
+	if m == nil {
+		return time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiry, true
+}
+
+// expiryDescription records the expiry in the description, replacing the
+// previous one and keeping the rest.
+func expiryDescription(description string, expiry time.Time) string {
+	lease := "expires=" + expiry.UTC().Format(time.RFC3339)
+	if expiryPattern.MatchString(description) {
+		return expiryPattern.ReplaceAllLiteralString(description, lease)
+	}
+	return strings.TrimSpace(description + " " + lease)
+}
+
+// checkTTL validates qingcloud-ttl.
+func (d *Driver) checkTTL() error {
+	if ttl, err := time.ParseDuration(d.TTL); err != nil || ttl <= 0 {
+		return fmt.Errorf("Invalid qingcloud-ttl [%s], expect a duration like 8h.", d.TTL)
+	}
+	return nil
+}
+
+// setLease records now + ttl as the expiry of the instance.
+func (d *Driver) setLease(ttl time.Duration) (time.Time, error) {
+	ins, err := d.getInstance()
+	if err != nil {
+		return time.Time{}, err
+	}
+	expiry := time.Now().Add(ttl)
+	description := expiryDescription(stringValue(ins.Description), expiry)
+	if err := d.GetClient().ModifyInstanceDescription(d.InstanceID, &description); err != nil {
+		return time.Time{}, err
+	}
+	log.Infof("Lease of Instance [%s] expires at [%s]", *d.InstanceID, expiry.Format(time.RFC3339))
+	return expiry, nil
+}
+
+// startLease records the expiry of qingcloud-ttl on a new machine.
+func (d *Driver) startLease() error {
+	if d.TTL == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(d.TTL)
+	if err != nil {
+		return err
+	}
+	_, err = d.setLease(ttl)
+	return err
+}
+
+// expiredInstance is a driver managed instance whose lease has expired.
+type expiredInstance struct {
+	Instance *qcservice.Instance
+	Zone     string
+	Machine  string
+	Expiry   time.Time
+}
+
+// expiredInstances finds the driver managed instances of the zones whose
+// lease expired before now.
+func (d *Driver) expiredInstances(zones []string, machines map[string]string, now time.Time) ([]*expiredInstance, error) {
+	expired := []*expiredInstance{}
+	for _, zone := range zones {
+		zd := d.zoneDriver(zone)
+		instances, err := zd.GetClient().ListInstances(true)
+		if err != nil {
+			return nil, fmt.Errorf("List instances of zone [%s] error: [%s]", zone, err.Error())
+		}
+		for _, ins := range instances {
+			expiry, ok := parseExpiry(stringValue(ins.Description))
+			if !ok || expiry.After(now) {
+				continue
+			}
+			expired = append(expired, &expiredInstance{Instance: ins, Zone: zone, Machine: machines[*ins.InstanceID], Expiry: expiry})
+		}
+	}
+	return expired, nil
+}
+
+// instanceDriver returns a driver for an instance which is not in the store,
+// with the resources the driver created for it. Like gc, the EIP and security
+// group of the instance are its own when they have the driver tag and are named
+// after the instance, and a keypair when it has the driver tag and is only
+// attached to the instance.
+func (d *Driver) instanceDriver(zone string, ins *qcservice.Instance) (*Driver, error) {
+	zd := d.zoneDriver(zone)
+	zd.InstanceID = ins.InstanceID
+	client := zd.GetClient()
+	if ins.EIP != nil && ins.EIP.EIPID != nil && *ins.EIP.EIPID != "" {
+		eips, err := client.ListEIPs(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, eip := range eips {
+			if stringValue(eip.EIPID) == *ins.EIP.EIPID && ownerOf(eip.EIPName) == *ins.InstanceID {
+				zd.EIP = eip
+			}
+		}
+	}
+	if ins.SecurityGroup != nil && ins.SecurityGroup.SecurityGroupID != nil && *ins.SecurityGroup.SecurityGroupID != "" {
+		sgs, err := client.ListSecurityGroups(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, sg := range sgs {
+			if stringValue(sg.SecurityGroupID) == *ins.SecurityGroup.SecurityGroupID && ownerOf(sg.SecurityGroupName) == *ins.InstanceID {
+				zd.SecurityGroup = sg
+			}
+		}
+	}
+	keyPairs, err := client.ListKeyPairs(true)
+	if err != nil {
+		return nil, err
+	}
+	zd.createdKeyPairs = nil
+	for _, kp := range keyPairs {
+		if kp.KeyPairID != nil && len(kp.InstanceIDs) == 1 && stringValue(kp.InstanceIDs[0]) == *ins.InstanceID {
+			zd.createdKeyPairs = append(zd.createdKeyPairs, *kp.KeyPairID)
+		}
+	}
+	return zd, nil
+}
+
+// expire removes the machine of the expired instance like docker-machine rm,
+// or terminates an instance which is not in the store and releases its EIP,
+// security group and keypairs.
+func (d *Driver) expire(store *machineStore, e *expiredInstance) error {
+	if e.Machine == "" {
+		zd, err := d.instanceDriver(e.Zone, e.Instance)
+		if err != nil {
+			return err
+		}
+		return zd.Remove()
+	}
+	_, md, err := store.Load(e.Machine)
+	if err != nil {
+		return err
+	}
+	// the lease ends the instance even if the machine would be detached on remove
+	md.DetachOnRemove = false
+	if err := md.Remove(); err != nil {
+		return err
+	}
+	return store.filestore().Remove(e.Machine)
+}
+
+func printExpiredInstances(w io.Writer, expired []*expiredInstance, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE\tZONE\tMACHINE\tSTATUS\tEXPIRED")
+	for _, e := range expired {
+		machine := e.Machine
+		if machine == "" {
+			machine = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s ago\n", *e.Instance.InstanceID, e.Zone, machine,
+			stringValue(e.Instance.Status), humanDuration(now.Sub(e.Expiry)))
+	}
+	tw.Flush()
+}
+
+func runExpire(args []string) error {
+	fs, store := newFlagSet("expire")
+	d := accountFlags(fs)
+	listZones := zonesFlag(fs, d, "expire")
+	yes := fs.Bool("yes", false, "terminate the expired instances, default is a dry run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	zones, err := listZones()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expired, err := d.expiredInstances(zones, store.machinesByInstance(), now)
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		fmt.Printf("No expired instances found in zones %v.\n", zones)
+		return nil
+	}
+	printExpiredInstances(os.Stdout, expired, now)
+	if !*yes {
+		fmt.Printf("\nDry run, %d expired instances found, run with --yes to terminate them.\n", len(expired))
+		return nil
+	}
+	failed := []string{}
+	for _, e := range expired {
+		if err := d.expire(store, e); err != nil {
+			log.Errorf("Expire Instance [%s] fail, err: [%s]", *e.Instance.InstanceID, err.Error())
+			failed = append(failed, *e.Instance.InstanceID)
+			continue
+		}
+		log.Infof("Terminated expired Instance [%s]", *e.Instance.InstanceID)
+	}
+	if len(failed) > 0 {
+		return errors.New("Terminate expired instances fail: " + strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func runExtend(args []string) error {
+	fs, store := newFlagSet("extend")
+	ttl := fs.Duration("ttl", 0, "new lease from now, default is the qingcloud-ttl of the machine")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	h, d, err := store.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *ttl == 0 {
+		if d.TTL == "" {
+			return fmt.Errorf("Machine [%s] has no qingcloud-ttl, --ttl required.", fs.Arg(0))
+		}
+		if *ttl, err = time.ParseDuration(d.TTL); err != nil {
+			return err
+		}
+	} else if *ttl < 0 {
+		return fmt.Errorf("Invalid ttl [%s].", *ttl)
+	}
+	expiry, err := d.setLease(*ttl)
+	if err != nil {
+		return err
+	}
+	d.TTL = ttl.String()
+	if err := store.Save(h, d); err != nil {
+		return err
+	}
+	fmt.Println(expiry.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,64 @@
+package qingcloud
+
+import (
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestExpiryDescription(t *testing.T) {
+	expiry := time.Date(2017, 5, 1, 8, 0, 0, 0, time.UTC)
+	cases := []struct {
+		description string
+		expect      string
+	}{
+		{"", "expires=2017-05-01T08:00:00Z"},
+		{"ci runner", "ci runner expires=2017-05-01T08:00:00Z"},
+		{"ci expires=2017-04-30T00:00:00Z runner", "ci expires=2017-05-01T08:00:00Z runner"},
+	}
+	for _, c := range cases {
+		description := expiryDescription(c.description, expiry)
+		if description != c.expect {
+			t.Errorf("expect description %q, but get %q", c.expect, description)
+		}
+		if e, ok := parseExpiry(description); !ok || !e.Equal(expiry) {
+			t.Errorf("expect expiry %s of %q, but get %s", expiry, description, e)
+		}
+	}
+	if _, ok := parseExpiry("expires=tomorrow"); ok {
+		t.Error("expect invalid expiry is ignored")
+	}
+}
+
+func TestExpireInstanceOutsideStore(t *testing.T) {
+	ins := &qcservice.Instance{
+		InstanceID: stringPtr("i-expired"),
+		Status:     stringPtr(INSTANCE_STATUS_RUNNING),
+		// the names returned with the instance are not trusted
+		EIP:           &qcservice.EIP{EIPID: stringPtr("eip-own"), EIPName: stringPtr("web")},
+		SecurityGroup: &qcservice.SecurityGroup{SecurityGroupID: stringPtr("sg-shared"), SecurityGroupName: stringPtr("i-expired")},
+	}
+	c := newFakeClient(ins)
+	c.eips = []*qcservice.EIP{{EIPID: stringPtr("eip-own"), EIPName: stringPtr("i-expired")}}
+	c.sgs = []*qcservice.SecurityGroup{{SecurityGroupID: stringPtr("sg-shared"), SecurityGroupName: stringPtr("i-expired")}}
+	c.keyPairs["kp-own"] = &qcservice.KeyPair{KeyPairID: stringPtr("kp-own"), InstanceIDs: []*string{stringPtr("i-expired")}}
+	c.keyPairs["kp-shared"] = &qcservice.KeyPair{KeyPairID: stringPtr("kp-shared"), InstanceIDs: []*string{stringPtr("i-expired"), stringPtr("i-other")}}
+	c.keyPairs["kp-user"] = &qcservice.KeyPair{KeyPairID: stringPtr("kp-user"), InstanceIDs: []*string{stringPtr("i-expired")}}
+	c.tagged["eip-own"], c.tagged["kp-own"], c.tagged["kp-shared"] = true, true, true
+	d := newFakeDriver(c, "")
+	e := &expiredInstance{Instance: ins, Zone: d.Zone, Expiry: time.Now().Add(-time.Hour)}
+	if err := d.expire(nil, e); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range []string{"TerminateInstance i-expired", "ReleaseEIP eip-own", "DeleteKeyPair kp-own"} {
+		if !c.called(call) {
+			t.Errorf("expect %s, but get calls %v", call, c.calls)
+		}
+	}
+	for _, call := range []string{"DeleteSecurityGroup sg-shared", "DeleteKeyPair kp-shared", "DeleteKeyPair kp-user"} {
+		if c.called(call) {
+			t.Errorf("expect no %s for a resource the driver didn't create", call)
+		}
+	}
+}
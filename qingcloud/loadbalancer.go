@@ -0,0 +1,189 @@
+package qingcloud
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// lbPort is a listener port of the load balancer and the backend port of the
+// machine, given as port or listener-port:backend-port.
+type lbPort struct {
+	Listener int
+	Backend  int
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("Invalid port [%s].", s)
+	}
+	return port, nil
+}
+
+func parseLBPort(s string) (*lbPort, error) {
+	parts := strings.SplitN(s, ":", 2)
+	listener, err := parsePort(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	p := &lbPort{Listener: listener, Backend: listener}
+	if len(parts) == 2 {
+		if p.Backend, err = parsePort(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// lbPorts returns the swarm port and the qingcloud-swarm-lb-port ports.
+func (d *Driver) lbPorts() ([]*lbPort, error) {
+	ports := []*lbPort{{Listener: swarmPort, Backend: swarmPort}}
+	for _, s := range d.SwarmLBPorts {
+		p, err := parseLBPort(s)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// joinLoadBalancer registers the swarm master as a backend of the load
+// balancer named qingcloud-swarm-lb, which is created if missing.
+func (d *Driver) joinLoadBalancer() error {
+	if !d.SwarmMaster || d.SwarmLB == "" {
+		return nil
+	}
+	ports, err := d.lbPorts()
+	if err != nil {
+		return err
+	}
+	client := d.GetClient()
+	lb, err := client.FindLoadBalancer(&d.SwarmLB)
+	if err != nil {
+		return err
+	}
+	var lbID *string
+	if lb != nil {
+		lbID = lb.LoadBalancerID
+	} else {
+		eipIDs := []*string{}
+		if d.VxNet == defaultVxNet {
+			eip, err := client.AllocateEIP(&d.SwarmLB)
+			if err != nil {
+				return err
+			}
+			d.tagResource("eip", eip.EIPID)
+			eipIDs = append(eipIDs, eip.EIPID)
+		}
+		log.Infof("Creating LoadBalancer [%s]...", d.SwarmLB)
+		lbID, err = client.CreateLoadBalancer(&d.SwarmLB, eipIDs, &d.VxNet)
+		if err != nil {
+			return err
+		}
+		d.tagResource("loadbalancer", lbID)
+	}
+	d.LoadBalancer = *lbID
+
+	listeners, err := client.DescribeLoadBalancerListeners(lbID)
+	if err != nil {
+		return err
+	}
+	rules := []*qcservice.SecurityGroupRule{}
+	for i, p := range ports {
+		var listenerID *string
+		for _, l := range listeners {
+			if l.ListenerPort != nil && *l.ListenerPort == p.Listener {
+				listenerID = l.LoadBalancerListenerID
+				break
+			}
+		}
+		if listenerID == nil {
+			if listenerID, err = client.AddLoadBalancerListener(lbID, p.Listener); err != nil {
+				return err
+			}
+		}
+		backendID, err := client.AddLoadBalancerBackend(listenerID, d.InstanceID, p.Backend)
+		if err != nil {
+			return err
+		}
+		d.LBBackends = append(d.LBBackends, *backendID)
+		rules = append(rules, &qcservice.SecurityGroupRule{
+			Priority: intPtr(len(defaultSecurityGroupRules) + i),
+			Protocol: stringPtr("tcp"),
+			Action:   stringPtr("accept"),
+			Val1:     stringPtr(strconv.Itoa(p.Backend)),
+		})
+	}
+	if err := client.UpdateLoadBalancer(lbID); err != nil {
+		return err
+	}
+	if d.SecurityGroup != nil {
+		if err := client.AddSecurityGroupRules(d.SecurityGroup.SecurityGroupID, d.InstanceID, rules); err != nil {
+			return err
+		}
+	}
+	log.Infof("Registered Instance [%s] to LoadBalancer [%s] as backends %v", *d.InstanceID, d.LoadBalancer, d.LBBackends)
+	return nil
+}
+
+// leaveLoadBalancer deregisters the machine, and deletes the load balancer
+// and the EIP allocated for it when no backend is left.
+func (d *Driver) leaveLoadBalancer() error {
+	if d.LoadBalancer == "" {
+		return nil
+	}
+	client := d.GetClient()
+	if len(d.LBBackends) > 0 {
+		backendIDs := []*string{}
+		for _, id := range d.LBBackends {
+			backendIDs = append(backendIDs, stringPtr(id))
+		}
+		if err := client.DeleteLoadBalancerBackends(backendIDs); err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("Delete LoadBalancer backends %v fail, err: [%s]", d.LBBackends, err.Error())
+		}
+		d.LBBackends = nil
+	}
+	backends, err := client.DescribeLoadBalancerBackends(&d.LoadBalancer)
+	if isNotFoundError(err) {
+		d.LoadBalancer = ""
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Describe LoadBalancer [%s] backends fail, err: [%s]", d.LoadBalancer, err.Error())
+	}
+	if len(backends) > 0 {
+		if err := client.UpdateLoadBalancer(&d.LoadBalancer); err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("Update LoadBalancer [%s] fail, err: [%s]", d.LoadBalancer, err.Error())
+		}
+		return nil
+	}
+	lb, err := client.DescribeLoadBalancer(&d.LoadBalancer)
+	if isNotFoundError(err) {
+		d.LoadBalancer = ""
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Describe LoadBalancer [%s] fail, err: [%s]", d.LoadBalancer, err.Error())
+	}
+	log.Infof("Deleting LoadBalancer [%s] without backends...", d.LoadBalancer)
+	if err := client.DeleteLoadBalancer(&d.LoadBalancer); err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("Delete LoadBalancer [%s] fail, err: [%s]", d.LoadBalancer, err.Error())
+	}
+	errs := multiError{}
+	for _, eip := range lb.EIPs {
+		// only the EIP allocated by joinLoadBalancer is named after the load balancer
+		if eip.EIPID == nil || stringValue(eip.EIPName) != stringValue(lb.LoadBalancerName) {
+			continue
+		}
+		if err := client.ReleaseEIP(eip.EIPID); err != nil && !isNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("Release EIP [%s] fail, err: [%s]", *eip.EIPID, err.Error()))
+		}
+	}
+	d.LoadBalancer = ""
+	return errs.errorOrNil()
+}
@@ -0,0 +1,33 @@
+package qingcloud
+
+import (
+	"testing"
+)
+
+func TestParseLBPort(t *testing.T) {
+	p, err := parseLBPort("80")
+	if err != nil || p.Listener != 80 || p.Backend != 80 {
+		t.Errorf("unexpected port %+v, err: %v", p, err)
+	}
+	p, err = parseLBPort("443:8443")
+	if err != nil || p.Listener != 443 || p.Backend != 8443 {
+		t.Errorf("unexpected port %+v, err: %v", p, err)
+	}
+	for _, s := range []string{"", "http", "0", "80:", "80:70000"} {
+		if _, err := parseLBPort(s); err == nil {
+			t.Errorf("expect error for [%s]", s)
+		}
+	}
+}
+
+func TestLBPorts(t *testing.T) {
+	d := NewDriver("test", "")
+	d.SwarmLBPorts = []string{"80:8080"}
+	ports, err := d.lbPorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 2 || ports[0].Listener != swarmPort || ports[1].Backend != 8080 {
+		t.Errorf("unexpected ports %+v", ports)
+	}
+}
@@ -0,0 +1,254 @@
+package qingcloud
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// metricLabel is a label of a sample in the prometheus text format.
+type metricLabel struct {
+	Name  string
+	Value string
+}
+
+type metricSample struct {
+	Labels []metricLabel
+	Value  float64
+}
+
+type metricFamily struct {
+	Name    string
+	Help    string
+	Samples []*metricSample
+}
+
+// metricSet is a set of gauges written in the prometheus text format, in the
+// order they are first added.
+type metricSet struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{index: map[string]*metricFamily{}}
+}
+
+func (m *metricSet) add(name, help string, value float64, labels ...metricLabel) {
+	f, ok := m.index[name]
+	if !ok {
+		f = &metricFamily{Name: name, Help: help}
+		m.index[name] = f
+		m.families = append(m.families, f)
+	}
+	f.Samples = append(f.Samples, &metricSample{Labels: labels, Value: value})
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (m *metricSet) write(w io.Writer) {
+	for _, f := range m.families {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", f.Name, f.Help, f.Name)
+		for _, s := range f.Samples {
+			labels := make([]string, 0, len(s.Labels))
+			for _, l := range s.Labels {
+				labels = append(labels, fmt.Sprintf(`%s="%s"`, l.Name, labelValueEscaper.Replace(l.Value)))
+			}
+			fmt.Fprintf(w, "%s{%s} %s\n", f.Name, strings.Join(labels, ","), strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+	}
+}
+
+// withLabels returns a copy of base with the labels appended.
+func withLabels(base []metricLabel, labels ...metricLabel) []metricLabel {
+	return append(append([]metricLabel{}, base...), labels...)
+}
+
+// addDirections adds the values of a meter reported as [in, out] or
+// [read, write] as samples with a direction label.
+func (m *metricSet) addDirections(name, help string, values []float64, directions [2]string, labels []metricLabel) {
+	for i, direction := range directions {
+		if i < len(values) {
+			m.add(name, help, values[i], withLabels(labels, metricLabel{"direction", direction})...)
+		}
+	}
+}
+
+func instanceTags(ins *qcservice.Instance) string {
+	tags := []string{}
+	for _, tag := range ins.Tags {
+		if tag.TagName != nil {
+			tags = append(tags, *tag.TagName)
+		}
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+// instanceLabels returns the labels of the samples of the instance. The ID is
+// labelled instance_id, as prometheus sets instance to the scraped target, and
+// the machine is only labelled for instances in the store.
+func instanceLabels(ins *qcservice.Instance, zone, machine string) []metricLabel {
+	labels := []metricLabel{}
+	if machine != "" {
+		labels = append(labels, metricLabel{"machine", machine})
+	}
+	return append(labels,
+		metricLabel{"instance_id", *ins.InstanceID},
+		metricLabel{"zone", zone},
+		metricLabel{"tags", instanceTags(ins)},
+	)
+}
+
+// collectInstanceMetrics adds the cpu, memory, disk and network meters of the
+// instance, and the bandwidth of its EIP.
+func collectInstanceMetrics(client Client, m *metricSet, ins *qcservice.Instance, labels []metricLabel) error {
+	meters := []string{"cpu", "memory", "disk-os", "disk-iops-os"}
+	disks := map[string]string{"disk-os": "os", "disk-iops-os": "os"}
+	for _, id := range ins.VolumeIDs {
+		meters = append(meters, "disk-"+*id, "disk-iops-"+*id)
+		disks["disk-"+*id] = *id
+		disks["disk-iops-"+*id] = *id
+	}
+	nics := map[string]*qcservice.VxNet{}
+	for _, vxnet := range ins.VxNets {
+		if vxnet.NICID != nil {
+			meters = append(meters, "if-"+*vxnet.NICID)
+			nics["if-"+*vxnet.NICID] = vxnet
+		}
+	}
+	start := time.Now().Add(-monitorWindow)
+	data, err := client.GetInstanceMonitor(ins.InstanceID, meters, monitorStep, start)
+	if err != nil {
+		return err
+	}
+	for _, meter := range data {
+		id := stringValue(meter.MeterID)
+		values, ok := meter.Latest()
+		if !ok {
+			continue
+		}
+		switch {
+		case id == "cpu":
+			m.add("qingcloud_instance_cpu_usage_ratio", "CPU usage of the instance.", values[0]/1000, labels...)
+		case id == "memory":
+			m.add("qingcloud_instance_memory_usage_ratio", "Memory usage of the instance.", values[0]/1000, labels...)
+		case strings.HasPrefix(id, "disk-iops-"):
+			m.addDirections("qingcloud_instance_disk_iops", "Disk IOPS of the instance.", values,
+				[2]string{"read", "write"}, withLabels(labels, metricLabel{"disk", disks[id]}))
+		case strings.HasPrefix(id, "disk-"):
+			m.addDirections("qingcloud_instance_disk_throughput", "Disk throughput of the instance.", values,
+				[2]string{"read", "write"}, withLabels(labels, metricLabel{"disk", disks[id]}))
+		case strings.HasPrefix(id, "if-"):
+			vxnet := nics[id]
+			if vxnet == nil {
+				continue
+			}
+			m.addDirections("qingcloud_instance_network_traffic", "Network traffic of the nic of the instance.", values,
+				[2]string{"in", "out"}, withLabels(labels, metricLabel{"nic", *vxnet.NICID}, metricLabel{"vxnet", stringValue(vxnet.VxNetID)}))
+		}
+	}
+
+	if ins.EIP == nil || ins.EIP.EIPID == nil || *ins.EIP.EIPID == "" {
+		return nil
+	}
+	data, err = client.GetEIPMonitor(ins.EIP.EIPID, []string{"traffic"}, monitorStep, start)
+	if err != nil {
+		return err
+	}
+	for _, meter := range data {
+		if values, ok := meter.Latest(); ok {
+			m.addDirections("qingcloud_eip_bandwidth", "Bandwidth of the EIP of the instance.", values, [2]string{"in", "out"},
+				withLabels(labels, metricLabel{"eip", *ins.EIP.EIPID}, metricLabel{"address", stringValue(ins.EIP.EIPAddr)}))
+		}
+	}
+	return nil
+}
+
+// collectMetrics collects the metrics of the driver managed instances of the
+// zones. Monitor errors of an instance are logged and do not fail the others.
+func (d *Driver) collectMetrics(zones []string, machines map[string]string) (*metricSet, error) {
+	m := newMetricSet()
+	for _, zone := range zones {
+		zd := d.zoneDriver(zone)
+		client := zd.GetClient()
+		instances, err := client.ListInstances(true)
+		if err != nil {
+			return nil, fmt.Errorf("List instances of zone [%s] error: [%s]", zone, err.Error())
+		}
+		for _, ins := range instances {
+			labels := instanceLabels(ins, zone, machines[*ins.InstanceID])
+			running := 0.0
+			if stringValue(ins.Status) == INSTANCE_STATUS_RUNNING {
+				running = 1
+			}
+			m.add("qingcloud_instance_running", "Whether the instance is running.", running, labels...)
+			if running == 0 {
+				continue
+			}
+			if err := collectInstanceMetrics(client, m, ins, labels); err != nil {
+				log.Warnf("Get monitor of Instance [%s] fail, err: [%s]", *ins.InstanceID, err.Error())
+			}
+		}
+	}
+	return m, nil
+}
+
+// metricsHandler serves the metrics, collected at most once per interval as
+// QingCloud monitor data has a 5 minutes step.
+type metricsHandler struct {
+	sync.Mutex
+	collect   func() (*metricSet, error)
+	interval  time.Duration
+	metrics   *metricSet
+	collected time.Time
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	if h.metrics == nil || time.Since(h.collected) >= h.interval {
+		m, err := h.collect()
+		if err != nil {
+			log.Errorf("Collect metrics fail, err: [%s]", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.metrics, h.collected = m, time.Now()
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	h.metrics.write(w)
+}
+
+func runMetrics(args []string) error {
+	fs, store := newFlagSet("metrics")
+	d := accountFlags(fs)
+	listZones := zonesFlag(fs, d, "collect")
+	listen := fs.String("listen", ":9469", "address to serve the prometheus endpoint /metrics on")
+	interval := fs.Duration("interval", time.Minute, "minimal interval between two collections from the QingCloud API")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	zones, err := listZones()
+	if err != nil {
+		return err
+	}
+	handler := &metricsHandler{
+		interval: *interval,
+		collect: func() (*metricSet, error) {
+			return d.collectMetrics(zones, store.machinesByInstance())
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	log.Infof("Serving metrics of zones %v on [%s/metrics]", zones, *listen)
+	return http.ListenAndServe(*listen, mux)
+}
@@ -0,0 +1,64 @@
+package qingcloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestMonitorMeterLatest(t *testing.T) {
+	cases := []struct {
+		data   string
+		expect []float64
+	}{
+		{`[[1390622400,50],60,"NA"]`, []float64{60}},
+		{`"[[1390622400,[1,2]],[3,4]]"`, []float64{3, 4}},
+		{`[[1390622400,[5,6]],"NA"]`, []float64{5, 6}},
+		{`[]`, nil},
+	}
+	for _, c := range cases {
+		meter := &MonitorMeter{}
+		if err := json.Unmarshal([]byte(`{"data":`+c.data+`}`), meter); err != nil {
+			t.Errorf("decode %s error: %s", c.data, err.Error())
+			continue
+		}
+		values, ok := meter.Latest()
+		if ok != (c.expect != nil) || !reflect.DeepEqual(values, c.expect) {
+			t.Errorf("expect latest of %s is %v, but get %v", c.data, c.expect, values)
+		}
+	}
+}
+
+func TestMetricSetWrite(t *testing.T) {
+	m := newMetricSet()
+	labels := []metricLabel{{"machine", `a"b`}, {"zone", "pek3a"}}
+	m.add("qingcloud_instance_cpu_usage_ratio", "CPU usage of the instance.", 0.25, labels...)
+	m.addDirections("qingcloud_eip_bandwidth", "Bandwidth.", []float64{1, 2}, [2]string{"in", "out"}, labels)
+	buf := &bytes.Buffer{}
+	m.write(buf)
+	expect := `# HELP qingcloud_instance_cpu_usage_ratio CPU usage of the instance.
+# TYPE qingcloud_instance_cpu_usage_ratio gauge
+qingcloud_instance_cpu_usage_ratio{machine="a\"b",zone="pek3a"} 0.25
+# HELP qingcloud_eip_bandwidth Bandwidth.
+# TYPE qingcloud_eip_bandwidth gauge
+qingcloud_eip_bandwidth{machine="a\"b",zone="pek3a",direction="in"} 1
+qingcloud_eip_bandwidth{machine="a\"b",zone="pek3a",direction="out"} 2
+`
+	if buf.String() != expect {
+		t.Errorf("expect:\n%s\nbut get:\n%s", expect, buf.String())
+	}
+}
+
+func TestInstanceLabels(t *testing.T) {
+	ins := &qcservice.Instance{InstanceID: stringPtr("i-test"), Tags: []*qcservice.Tag{{TagName: stringPtr("web")}, {TagName: stringPtr("docker-machine")}}}
+	expect := []metricLabel{{"machine", "web"}, {"instance_id", "i-test"}, {"zone", "pek3a"}, {"tags", "docker-machine,web"}}
+	if labels := instanceLabels(ins, "pek3a", "web"); !reflect.DeepEqual(labels, expect) {
+		t.Errorf("expect labels %v, but get %v", expect, labels)
+	}
+	if labels := instanceLabels(ins, "pek3a", ""); !reflect.DeepEqual(labels, expect[1:]) {
+		t.Errorf("expect no machine label outside the store, but get %v", labels)
+	}
+}
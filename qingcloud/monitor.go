@@ -0,0 +1,152 @@
+package qingcloud
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/yunify/qingcloud-sdk-go/request"
+	"github.com/yunify/qingcloud-sdk-go/request/data"
+)
+
+// The sdk decodes the data of a meter as a string, which fails on meters
+// without data, GetMonitor is sent through the sdk request package like
+// GetQuotaLeft and the data is kept as raw json.
+
+// monitorWindow is how far back recent monitor data is requested, the latest
+// points of QingCloud monitor lag behind by a few minutes.
+const (
+	monitorStep   = "5m"
+	monitorWindow = 30 * time.Minute
+)
+
+// monitorStepFor returns a step of GetMonitor which keeps the number of points
+// of the window small.
+func monitorStepFor(window time.Duration) string {
+	switch {
+	case window <= 6*time.Hour:
+		return "5m"
+	case window <= 24*time.Hour:
+		return "15m"
+	case window <= 15*24*time.Hour:
+		return "2h"
+	}
+	return "1d"
+}
+
+type getMonitorInput struct {
+	EndTime   *time.Time `json:"end_time" name:"end_time" format:"ISO 8601" location:"params"`
+	Meters    []*string  `json:"meters" name:"meters" location:"params"`
+	Resource  *string    `json:"resource" name:"resource" location:"params"`
+	StartTime *time.Time `json:"start_time" name:"start_time" format:"ISO 8601" location:"params"`
+	Step      *string    `json:"step" name:"step" location:"params"`
+}
+
+func (v *getMonitorInput) Validate() error {
+	return nil
+}
+
+// monitorData is the compressed data of a meter, [[timestamp, value], value, ...],
+// where a value is a number, a list of numbers, or "NA".
+type monitorData []json.RawMessage
+
+func (m *monitorData) UnmarshalJSON(b []byte) error {
+	// the sdk unpacker quotes monitor data as a json string before decoding
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		b = []byte(s)
+	}
+	var points []json.RawMessage
+	if err := json.Unmarshal(b, &points); err != nil {
+		return err
+	}
+	*m = points
+	return nil
+}
+
+// MonitorMeter is a meter returned by GetMonitor.
+type MonitorMeter struct {
+	MeterID *string     `json:"meter_id" name:"meter_id"`
+	VxNetID *string     `json:"vxnet_id" name:"vxnet_id"`
+	Data    monitorData `json:"data" name:"data"`
+}
+
+// Points returns the values of the points which are not "NA".
+func (m *MonitorMeter) Points() [][]float64 {
+	points := [][]float64{}
+	for i, point := range m.Data {
+		if i == 0 {
+			var first []json.RawMessage
+			if err := json.Unmarshal(point, &first); err == nil && len(first) == 2 {
+				point = first[1]
+			}
+		}
+		var value float64
+		if err := json.Unmarshal(point, &value); err == nil {
+			points = append(points, []float64{value})
+			continue
+		}
+		var values []float64
+		if err := json.Unmarshal(point, &values); err == nil && len(values) > 0 {
+			points = append(points, values)
+		}
+	}
+	return points
+}
+
+// Latest returns the values of the latest point which is not "NA".
+func (m *MonitorMeter) Latest() ([]float64, bool) {
+	points := m.Points()
+	if len(points) == 0 {
+		return nil, false
+	}
+	return points[len(points)-1], true
+}
+
+type getMonitorOutput struct {
+	Message  *string         `json:"message" name:"message"`
+	Action   *string         `json:"action" name:"action" location:"elements"`
+	MeterSet []*MonitorMeter `json:"meter_set" name:"meter_set" location:"elements"`
+	RetCode  *int            `json:"ret_code" name:"ret_code" location:"elements"`
+}
+
+func (c *client) getMonitor(properties interface{}, resourceID *string, meters []string, step string, start time.Time) ([]*MonitorMeter, error) {
+	end := time.Now()
+	input := &getMonitorInput{
+		Resource:  resourceID,
+		Step:      stringPtr(step),
+		StartTime: &start,
+		EndTime:   &end,
+	}
+	for _, m := range meters {
+		input.Meters = append(input.Meters, stringPtr(m))
+	}
+	o := &data.Operation{
+		Config:        c.instanceService.Config,
+		Properties:    properties,
+		APIName:       "GetMonitor",
+		RequestMethod: "GET",
+	}
+	x := &getMonitorOutput{}
+	r, err := request.New(o, input, x)
+	if err != nil {
+		return nil, err
+	}
+	err = r.Send()
+	if err != nil {
+		return nil, err
+	}
+	return x.MeterSet, nil
+}
+
+// GetInstanceMonitor returns the data of the meters of the instance since start.
+func (c *client) GetInstanceMonitor(instanceID *string, meters []string, step string, start time.Time) ([]*MonitorMeter, error) {
+	return c.getMonitor(c.instanceService.Properties, instanceID, meters, step, start)
+}
+
+// GetEIPMonitor returns the data of the meters of the EIP since start.
+func (c *client) GetEIPMonitor(eipID *string, meters []string, step string, start time.Time) ([]*MonitorMeter, error) {
+	return c.getMonitor(c.eipService.Properties, eipID, meters, step, start)
+}
@@ -0,0 +1,114 @@
+package qingcloud
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// nicSpec is an extra NIC given as vxnet-id[:private-ip].
+type nicSpec struct {
+	VxNet     string
+	PrivateIP string
+}
+
+func parseNicSpec(s string) (*nicSpec, error) {
+	spec := &nicSpec{VxNet: s}
+	if i := strings.Index(s, ":"); i >= 0 {
+		spec.VxNet, spec.PrivateIP = s[:i], s[i+1:]
+		if net.ParseIP(spec.PrivateIP) == nil {
+			return nil, fmt.Errorf("Invalid private IP [%s] of NIC [%s].", spec.PrivateIP, s)
+		}
+	}
+	if spec.VxNet == "" {
+		return nil, fmt.Errorf("Invalid NIC [%s], expect vxnet-id[:private-ip].", s)
+	}
+	if spec.VxNet == defaultVxNet {
+		return nil, fmt.Errorf("NIC [%s] can not be in the base network [%s].", s, defaultVxNet)
+	}
+	return spec, nil
+}
+
+// checkNics validates qingcloud-extra-nic and qingcloud-primary-vxnet.
+func (d *Driver) checkNics() []error {
+	errs := []error{}
+	vxnets := map[string]bool{d.VxNet: true}
+	for _, s := range d.ExtraNics {
+		spec, err := parseNicSpec(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if vxnets[spec.VxNet] {
+			errs = append(errs, fmt.Errorf("VxNet [%s] of NIC [%s] is already used by the instance.", spec.VxNet, s))
+			continue
+		}
+		vxnets[spec.VxNet] = true
+		if _, err := d.GetClient().DescribeVxNet(&spec.VxNet); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if d.PrimaryVxNet != "" && !vxnets[d.PrimaryVxNet] {
+		errs = append(errs, fmt.Errorf("Param error: qingcloud-primary-vxnet [%s] should be qingcloud-vxnet-id or the vxnet of a qingcloud-extra-nic.", d.PrimaryVxNet))
+	}
+	return errs
+}
+
+// attachExtraNics creates the qingcloud-extra-nic NICs and attaches them to
+// the instance. The created NICs are saved, so remove can delete them.
+func (d *Driver) attachExtraNics() error {
+	if len(d.ExtraNics) == 0 {
+		return nil
+	}
+	client := d.GetClient()
+	nicIDs := []*string{}
+	for _, s := range d.ExtraNics {
+		spec, err := parseNicSpec(s)
+		if err != nil {
+			return err
+		}
+		nicID, err := client.CreateNic(&spec.VxNet, &spec.PrivateIP, d.InstanceID)
+		if err != nil {
+			return err
+		}
+		log.Infof("Created NIC [%s] in VxNet [%s]", *nicID, spec.VxNet)
+		d.Nics = append(d.Nics, *nicID)
+		nicIDs = append(nicIDs, nicID)
+	}
+	log.Infof("Attaching NICs %v to Instance [%s]...", d.Nics, *d.InstanceID)
+	if err := client.AttachNics(d.InstanceID, nicIDs); err != nil {
+		return err
+	}
+	if d.PrimaryVxNet != "" && d.PrimaryVxNet != d.VxNet {
+		ins, err := d.getInstance()
+		if err != nil {
+			return err
+		}
+		d.IPAddress = d.instanceAddress(ins)
+		log.Infof("Use IPAddress [%s] of VxNet [%s]", d.IPAddress, d.PrimaryVxNet)
+	}
+	return nil
+}
+
+// removeNics detaches and deletes the NICs created by the driver.
+func (d *Driver) removeNics() error {
+	if len(d.Nics) == 0 {
+		return nil
+	}
+	nicIDs := []*string{}
+	for _, id := range d.Nics {
+		nicIDs = append(nicIDs, stringPtr(id))
+	}
+	client := d.GetClient()
+	if err := client.DetachNics(nicIDs); err != nil {
+		// NICs of a terminated instance are already detached
+		log.Warnf("Detach NICs %v fail, err: [%s]", d.Nics, err.Error())
+	}
+	if err := client.DeleteNics(nicIDs); err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("Delete NICs %v fail, err: [%s]", d.Nics, err.Error())
+	}
+	d.Nics = nil
+	return nil
+}
@@ -0,0 +1,21 @@
+package qingcloud
+
+import (
+	"testing"
+)
+
+func TestParseNicSpec(t *testing.T) {
+	spec, err := parseNicSpec("vxnet-data")
+	if err != nil || spec.VxNet != "vxnet-data" || spec.PrivateIP != "" {
+		t.Errorf("unexpected nic spec %+v, err: %v", spec, err)
+	}
+	spec, err = parseNicSpec("vxnet-data:192.168.100.10")
+	if err != nil || spec.VxNet != "vxnet-data" || spec.PrivateIP != "192.168.100.10" {
+		t.Errorf("unexpected nic spec %+v, err: %v", spec, err)
+	}
+	for _, s := range []string{"", ":192.168.100.10", "vxnet-data:bad", defaultVxNet} {
+		if _, err := parseNicSpec(s); err == nil {
+			t.Errorf("expect error for [%s]", s)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package qingcloud
+
+import (
+	"github.com/yunify/qingcloud-sdk-go/request"
+	"github.com/yunify/qingcloud-sdk-go/request/data"
+)
+
+// The vendored sdk has no quota API, GetQuotaLeft is sent through the sdk
+// request package with the same input/output conventions as the sdk services.
+
+type getQuotaLeftInput struct {
+	ResourceTypes []*string `json:"resource_types" name:"resource_types" location:"params"`
+}
+
+func (v *getQuotaLeftInput) Validate() error {
+	return nil
+}
+
+type quotaLeft struct {
+	ResourceType *string `json:"resource_type" name:"resource_type"`
+	Left         *int    `json:"left" name:"left"`
+}
+
+type getQuotaLeftOutput struct {
+	Message      *string      `json:"message" name:"message"`
+	Action       *string      `json:"action" name:"action" location:"elements"`
+	QuotaLeftSet []*quotaLeft `json:"quota_left_set" name:"quota_left_set" location:"elements"`
+	RetCode      *int         `json:"ret_code" name:"ret_code" location:"elements"`
+}
+
+// GetQuotaLeft returns the quota left of the resource types in the zone.
+func (c *client) GetQuotaLeft(resourceTypes []string) (map[string]int, error) {
+	input := &getQuotaLeftInput{}
+	for _, t := range resourceTypes {
+		input.ResourceTypes = append(input.ResourceTypes, stringPtr(t))
+	}
+	o := &data.Operation{
+		Config:        c.instanceService.Config,
+		Properties:    c.instanceService.Properties,
+		APIName:       "GetQuotaLeft",
+		RequestMethod: "GET",
+	}
+	x := &getQuotaLeftOutput{}
+	r, err := request.New(o, input, x)
+	if err != nil {
+		return nil, err
+	}
+	err = r.Send()
+	if err != nil {
+		return nil, err
+	}
+	left := map[string]int{}
+	for _, q := range x.QuotaLeftSet {
+		if q.ResourceType != nil && q.Left != nil {
+			left[*q.ResourceType] = *q.Left
+		}
+	}
+	return left, nil
+}
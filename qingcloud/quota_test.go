@@ -0,0 +1,53 @@
+package qingcloud
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckQuota(t *testing.T) {
+	c := newFakeClient()
+	c.quota = map[string]int{"instance": 0, "eip": 1, "security_group": 0, "keypair": 3}
+	d := newFakeDriver(c, "")
+	d.VxNet = defaultVxNet
+	errs := d.checkQuota()
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "[instance]") || !strings.Contains(errs[1].Error(), "[security_group]") {
+		t.Errorf("expect instance and security_group quota errors, but get %v", errs)
+	}
+	if !c.called("GetQuotaLeft eip,instance,keypair,security_group") {
+		t.Errorf("expect quota of the resources the create needs, but get calls %v", c.calls)
+	}
+
+	// with failover zones a shortfall is only logged
+	d.Zones = []string{d.Zone, "sh1a"}
+	if errs := d.checkQuota(); len(errs) != 0 {
+		t.Errorf("expect no quota errors with failover zones, but get %v", errs)
+	}
+
+	// the create goes on when the quota can not be read
+	c.quota = nil
+	d.Zones = nil
+	if errs := d.checkQuota(); len(errs) != 0 {
+		t.Errorf("expect quota check skipped, but get %v", errs)
+	}
+}
+
+func TestPreCreateCheckReportsAllErrors(t *testing.T) {
+	c := newFakeClient()
+	c.quota = map[string]int{"instance": 0, "keypair": 1}
+	d := newFakeDriver(c, "")
+	d.VxNet = "vxnet-missing"
+	d.Image = "img-missing"
+	err := d.PreCreateCheck()
+	if err == nil {
+		t.Fatal("expect errors")
+	}
+	for _, expect := range []string{"VxNet with id [vxnet-missing] not exist.", "Image [img-missing] not found.", "Quota of [instance] is used up"} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("expect [%s] reported, but get:\n%s", expect, err)
+		}
+	}
+	if errs, ok := err.(multiError); !ok || len(errs) != 3 {
+		t.Errorf("expect 3 errors reported together, but get %#v", err)
+	}
+}
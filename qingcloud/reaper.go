@@ -0,0 +1,267 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+const (
+	idleActionStop      = "stop"
+	idleActionTerminate = "terminate"
+
+	defaultIdleTTL    = 72 * time.Hour
+	defaultExcludeTag = "reaper-exclude"
+)
+
+// reapPolicy is the idle policy of an instance. The command options are
+// overridden by instance tags named ttl=<duration> and idle-action=stop|terminate.
+type reapPolicy struct {
+	TTL    time.Duration
+	Action string
+}
+
+func checkIdleAction(action string) error {
+	if action != idleActionStop && action != idleActionTerminate {
+		return fmt.Errorf("Invalid idle action [%s], expect %s or %s.", action, idleActionStop, idleActionTerminate)
+	}
+	return nil
+}
+
+// parseReapPolicy returns the policy of the tags, or false if the instance has
+// the exclusion tag.
+func parseReapPolicy(tags []string, defaults reapPolicy, excludeTag string) (reapPolicy, bool, error) {
+	p := defaults
+	for _, tag := range tags {
+		if tag == excludeTag {
+			return p, false, nil
+		}
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "ttl":
+			ttl, err := time.ParseDuration(parts[1])
+			if err != nil || ttl <= 0 {
+				return p, false, fmt.Errorf("Invalid tag [%s], expect a duration like ttl=72h.", tag)
+			}
+			p.TTL = ttl
+		case "idle-action":
+			if err := checkIdleAction(parts[1]); err != nil {
+				return p, false, err
+			}
+			p.Action = parts[1]
+		}
+	}
+	return p, true, nil
+}
+
+// idleThreshold is the usage under which an instance counts as idle.
+type idleThreshold struct {
+	// CPU is in percent, GetMonitor reports cpu in permille.
+	CPU float64
+	// Network is the traffic in and out of a nic at a point.
+	Network float64
+}
+
+// idleUsage returns the max cpu percent and nic traffic of the meters, and
+// whether every point is under the threshold. Without cpu points there is no
+// history and the instance is not idle.
+func idleUsage(meters []*MonitorMeter, threshold idleThreshold) (float64, float64, bool) {
+	maxCPU, maxNetwork := 0.0, 0.0
+	hasCPU := false
+	for _, meter := range meters {
+		id := stringValue(meter.MeterID)
+		for _, values := range meter.Points() {
+			switch {
+			case id == "cpu":
+				hasCPU = true
+				if cpu := values[0] / 10; cpu > maxCPU {
+					maxCPU = cpu
+				}
+			case strings.HasPrefix(id, "if-"):
+				traffic := 0.0
+				for _, v := range values {
+					traffic += v
+				}
+				if traffic > maxNetwork {
+					maxNetwork = traffic
+				}
+			}
+		}
+	}
+	return maxCPU, maxNetwork, hasCPU && maxCPU <= threshold.CPU && maxNetwork <= threshold.Network
+}
+
+// idleInstance is a running instance idle for longer than its policy ttl.
+type idleInstance struct {
+	Instance   *qcservice.Instance
+	Zone       string
+	Machine    string
+	Policy     reapPolicy
+	MaxCPU     float64
+	MaxNetwork float64
+}
+
+// idleInstances finds the driver managed instances of the zones which are
+// running, older than their ttl, and idle over the ttl.
+func (d *Driver) idleInstances(zones []string, machines map[string]string, defaults reapPolicy, excludeTag string, threshold idleThreshold) ([]*idleInstance, error) {
+	idle := []*idleInstance{}
+	for _, zone := range zones {
+		zd := d.zoneDriver(zone)
+		client := zd.GetClient()
+		instances, err := client.ListInstances(true)
+		if err != nil {
+			return nil, fmt.Errorf("List instances of zone [%s] error: [%s]", zone, err.Error())
+		}
+		for _, ins := range instances {
+			if stringValue(ins.Status) != INSTANCE_STATUS_RUNNING {
+				continue
+			}
+			tags := []string{}
+			for _, tag := range ins.Tags {
+				tags = append(tags, stringValue(tag.TagName))
+			}
+			policy, ok, err := parseReapPolicy(tags, defaults, excludeTag)
+			if err != nil {
+				log.Warnf("Skip Instance [%s]: %s", *ins.InstanceID, err.Error())
+				continue
+			}
+			if !ok || ins.CreateTime == nil || time.Since(*ins.CreateTime) < policy.TTL {
+				continue
+			}
+			meters := []string{"cpu"}
+			for _, vxnet := range ins.VxNets {
+				if vxnet.NICID != nil {
+					meters = append(meters, "if-"+*vxnet.NICID)
+				}
+			}
+			data, err := client.GetInstanceMonitor(ins.InstanceID, meters, monitorStepFor(policy.TTL), time.Now().Add(-policy.TTL))
+			if err != nil {
+				log.Warnf("Get monitor of Instance [%s] fail, err: [%s]", *ins.InstanceID, err.Error())
+				continue
+			}
+			maxCPU, maxNetwork, ok := idleUsage(data, threshold)
+			if !ok {
+				continue
+			}
+			idle = append(idle, &idleInstance{
+				Instance:   ins,
+				Zone:       zone,
+				Machine:    machines[*ins.InstanceID],
+				Policy:     policy,
+				MaxCPU:     maxCPU,
+				MaxNetwork: maxNetwork,
+			})
+		}
+	}
+	return idle, nil
+}
+
+// reap stops the idle instance, or snapshots and terminates it. The instance
+// is removed through a driver so its EIP and security group are released too,
+// a machine of the store is removed with its own driver and from the store.
+func (d *Driver) reap(store *machineStore, i *idleInstance) error {
+	zd, err := d.instanceDriver(i.Zone, i.Instance)
+	if err != nil {
+		return err
+	}
+	if i.Policy.Action == idleActionStop {
+		return zd.GetClient().StopInstance(zd.InstanceID, false)
+	}
+	snapshotIDs, err := zd.Backup()
+	if err != nil {
+		return err
+	}
+	log.Infof("Created snapshots %v of Instance [%s]", snapshotIDs, *zd.InstanceID)
+	if i.Machine == "" {
+		zd.SnapshotOnRemove = false
+		zd.DetachOnRemove = false
+		return zd.Remove()
+	}
+	_, md, err := store.Load(i.Machine)
+	if err != nil {
+		return err
+	}
+	// the snapshots are already taken, and the instance is terminated even if
+	// the machine would be detached on remove
+	md.SnapshotOnRemove = false
+	md.DetachOnRemove = false
+	if err := md.Remove(); err != nil {
+		return err
+	}
+	return store.filestore().Remove(i.Machine)
+}
+
+func printIdleInstances(w io.Writer, idle []*idleInstance) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE\tZONE\tMACHINE\tTTL\tMAX CPU\tMAX NETWORK\tACTION")
+	for _, i := range idle {
+		machine := i.Machine
+		if machine == "" {
+			machine = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.1f%%\t%s\t%s\n", *i.Instance.InstanceID, i.Zone, machine, i.Policy.TTL,
+			i.MaxCPU, strconv.FormatFloat(i.MaxNetwork, 'f', -1, 64), i.Policy.Action)
+	}
+	tw.Flush()
+}
+
+func runReap(args []string) error {
+	fs, store := newFlagSet("reap")
+	d := accountFlags(fs)
+	listZones := zonesFlag(fs, d, "reap")
+	defaults := reapPolicy{}
+	fs.DurationVar(&defaults.TTL, "ttl", defaultIdleTTL, "how long an instance must be idle, overridden by the instance tag ttl=<duration>")
+	fs.StringVar(&defaults.Action, "action", idleActionStop, "stop or terminate idle instances, overridden by the instance tag idle-action=stop|terminate")
+	excludeTag := fs.String("exclude-tag", defaultExcludeTag, "instances with this tag are never reaped")
+	threshold := idleThreshold{}
+	fs.Float64Var(&threshold.CPU, "idle-cpu", 5, "max cpu usage in percent of an idle instance")
+	fs.Float64Var(&threshold.Network, "idle-network", 20000, "max traffic in and out of a nic of an idle instance, as reported by the nic monitor")
+	yes := fs.Bool("yes", false, "stop or terminate the idle instances, default is a dry run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkIdleAction(defaults.Action); err != nil {
+		return err
+	}
+	zones, err := listZones()
+	if err != nil {
+		return err
+	}
+	idle, err := d.idleInstances(zones, store.machinesByInstance(), defaults, *excludeTag, threshold)
+	if err != nil {
+		return err
+	}
+	if len(idle) == 0 {
+		fmt.Printf("No idle instances found in zones %v.\n", zones)
+		return nil
+	}
+	printIdleInstances(os.Stdout, idle)
+	if !*yes {
+		fmt.Printf("\nDry run, %d idle instances found, run with --yes to stop or terminate them.\n", len(idle))
+		return nil
+	}
+	failed := []string{}
+	for _, i := range idle {
+		if err := d.reap(store, i); err != nil {
+			log.Errorf("Reap Instance [%s] fail, err: [%s]", *i.Instance.InstanceID, err.Error())
+			failed = append(failed, *i.Instance.InstanceID)
+			continue
+		}
+		log.Infof("Reaped Instance [%s] with action [%s]", *i.Instance.InstanceID, i.Policy.Action)
+	}
+	if len(failed) > 0 {
+		return errors.New("Reap idle instances fail: " + strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package qingcloud
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestParseReapPolicy(t *testing.T) {
+	defaults := reapPolicy{TTL: defaultIdleTTL, Action: idleActionStop}
+	p, ok, err := parseReapPolicy([]string{"docker-machine", "ttl=8h", "idle-action=terminate"}, defaults, defaultExcludeTag)
+	if err != nil || !ok || p.TTL != 8*time.Hour || p.Action != idleActionTerminate {
+		t.Errorf("unexpected policy %+v, %v, %v", p, ok, err)
+	}
+	if p, ok, err = parseReapPolicy([]string{"docker-machine"}, defaults, defaultExcludeTag); err != nil || !ok || p != defaults {
+		t.Errorf("expect default policy, but get %+v, %v, %v", p, ok, err)
+	}
+	if _, ok, _ = parseReapPolicy([]string{"ttl=1h", defaultExcludeTag}, defaults, defaultExcludeTag); ok {
+		t.Error("expect excluded instance is not reaped")
+	}
+	for _, tag := range []string{"ttl=forever", "ttl=-1h", "idle-action=delete"} {
+		if _, _, err = parseReapPolicy([]string{tag}, defaults, defaultExcludeTag); err == nil {
+			t.Errorf("expect error of tag %s", tag)
+		}
+	}
+}
+
+func TestIdleUsage(t *testing.T) {
+	meters := func(cpu, nic string) []*MonitorMeter {
+		m := []*MonitorMeter{{MeterID: stringPtr("cpu")}, {MeterID: stringPtr("if-52:54:00:00:00:01")}}
+		json.Unmarshal([]byte(cpu), &m[0].Data)
+		json.Unmarshal([]byte(nic), &m[1].Data)
+		return m
+	}
+	threshold := idleThreshold{CPU: 5, Network: 20000}
+	cases := []struct {
+		cpu, nic string
+		idle     bool
+	}{
+		{`[[1390622400,10],20,"NA",49]`, `[[1390622400,[1000,2000]],[500,500]]`, true},
+		{`[[1390622400,10],60]`, `[[1390622400,[1000,2000]]]`, false},
+		{`[[1390622400,10]]`, `[[1390622400,[15000,6000]]]`, false},
+		{`["NA"]`, `[]`, false},
+	}
+	for _, c := range cases {
+		maxCPU, maxNetwork, idle := idleUsage(meters(c.cpu, c.nic), threshold)
+		if idle != c.idle {
+			t.Errorf("expect idle %v of cpu %s nic %s, but get %v (%v, %v)", c.idle, c.cpu, c.nic, idle, maxCPU, maxNetwork)
+		}
+	}
+}
+
+func TestReapTerminateReleasesResources(t *testing.T) {
+	ins := &qcservice.Instance{
+		InstanceID:    stringPtr("i-idle"),
+		Status:        stringPtr(INSTANCE_STATUS_RUNNING),
+		EIP:           &qcservice.EIP{EIPID: stringPtr("eip-idle"), EIPName: stringPtr("i-idle")},
+		SecurityGroup: &qcservice.SecurityGroup{SecurityGroupID: stringPtr("sg-idle"), SecurityGroupName: stringPtr("i-idle")},
+	}
+	c := newFakeClient(ins)
+	c.eips = []*qcservice.EIP{ins.EIP}
+	c.sgs = []*qcservice.SecurityGroup{ins.SecurityGroup}
+	c.tagged["eip-idle"], c.tagged["sg-idle"] = true, true
+	d := newFakeDriver(c, "")
+	i := &idleInstance{Instance: ins, Zone: d.Zone, Policy: reapPolicy{TTL: time.Hour, Action: idleActionTerminate}}
+	if err := d.reap(nil, i); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range []string{"CreateSnapshots i-idle", "TerminateInstance i-idle", "ReleaseEIP eip-idle", "DeleteSecurityGroup sg-idle"} {
+		if !c.called(call) {
+			t.Errorf("expect %s, but get calls %v", call, c.calls)
+		}
+	}
+	if c.index("CreateSnapshots") > c.index("TerminateInstance") {
+		t.Errorf("expect snapshots before terminate, but get calls %v", c.calls)
+	}
+}
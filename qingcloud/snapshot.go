@@ -0,0 +1,175 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+// Backup creates snapshots of the instance root disk and attached volumes,
+// the snapshots are tagged with the driver tag and the instance name.
+func (d *Driver) Backup() ([]string, error) {
+	client := d.GetClient()
+	ins, err := d.getInstance()
+	if err != nil {
+		return nil, err
+	}
+	resources := append([]*string{d.InstanceID}, ins.VolumeIDs...)
+	name := *d.InstanceID
+	if ins.InstanceName != nil && *ins.InstanceName != "" {
+		name = *ins.InstanceName
+	}
+	snapshotName := fmt.Sprintf("%s-%s", name, time.Now().Format("20060102150405"))
+	log.Infof("Creating snapshot [%s] of Instance [%s]...", snapshotName, *d.InstanceID)
+	snapshotIDs, err := client.CreateSnapshots(resources, &snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	for _, tagName := range []string{DefaultTagName, name} {
+		err = client.TagResources(tagName, "snapshot", snapshotIDs)
+		if err != nil {
+			log.Warnf("Tag snapshots with [%s] fail, err: [%s]", tagName, err.Error())
+		}
+	}
+	ids := []string{}
+	for _, id := range snapshotIDs {
+		ids = append(ids, *id)
+	}
+	return ids, nil
+}
+
+// latestSnapshots returns the latest snapshot of the instance and each attached volume.
+func (d *Driver) latestSnapshots() ([]string, error) {
+	client := d.GetClient()
+	ins, err := d.getInstance()
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, resourceID := range append([]*string{d.InstanceID}, ins.VolumeIDs...) {
+		snapshots, err := client.DescribeSnapshots(resourceID)
+		if err != nil {
+			return nil, err
+		}
+		var latest *qcservice.Snapshot
+		for _, s := range snapshots {
+			if s.SnapshotTime == nil {
+				continue
+			}
+			if latest == nil || s.SnapshotTime.After(*latest.SnapshotTime) {
+				latest = s
+			}
+		}
+		if latest == nil {
+			if *resourceID == *d.InstanceID {
+				return nil, fmt.Errorf("No snapshot found for Instance [%s].", *d.InstanceID)
+			}
+			log.Warnf("No snapshot found for Volume [%s], skip it", *resourceID)
+			continue
+		}
+		ids = append(ids, *latest.SnapshotID)
+	}
+	return ids, nil
+}
+
+// Restore applies the snapshots to the instance and its volumes. The instance
+// is stopped before and started again after the snapshots are applied.
+func (d *Driver) Restore(snapshotIDs []string) error {
+	if len(snapshotIDs) == 0 {
+		return errors.New("Snapshots to restore can not be empty.")
+	}
+	ins, err := d.getInstance()
+	if err != nil {
+		return err
+	}
+	if ins.Status != nil && *ins.Status != INSTANCE_STATUS_STOPPED {
+		log.Infof("Stopping Instance [%s] before restore...", *d.InstanceID)
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+	log.Infof("Applying snapshots %v to Instance [%s]...", snapshotIDs, *d.InstanceID)
+	ids := []*string{}
+	for _, id := range snapshotIDs {
+		ids = append(ids, stringPtr(id))
+	}
+	if err := d.GetClient().ApplySnapshots(ids); err != nil {
+		return err
+	}
+	return d.Start()
+}
+
+// RestoreAsVolumes creates new volumes from the snapshots, returns the volume IDs.
+func (d *Driver) RestoreAsVolumes(snapshotIDs []string) ([]string, error) {
+	volumeIDs := []string{}
+	for _, id := range snapshotIDs {
+		volumeName := fmt.Sprintf("%s-%s", *d.InstanceID, id)
+		volumeID, err := d.GetClient().CreateVolumeFromSnapshot(stringPtr(id), &volumeName)
+		if err != nil {
+			return volumeIDs, err
+		}
+		log.Infof("Created Volume [%s] from snapshot [%s]", *volumeID, id)
+		volumeIDs = append(volumeIDs, *volumeID)
+	}
+	return volumeIDs, nil
+}
+
+func runBackup(args []string) error {
+	fs, store := newFlagSet("backup")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	_, d, err := store.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	snapshotIDs, err := d.Backup()
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.Join(snapshotIDs, ","))
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs, store := newFlagSet("restore")
+	snapshots := fs.String("snapshots", "", "comma separated snapshot ids, default is the latest snapshot of each disk")
+	asVolumes := fs.Bool("as-volumes", false, "create new volumes from the snapshots instead of applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Machine name required.")
+	}
+	_, d, err := store.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var snapshotIDs []string
+	if *snapshots != "" {
+		snapshotIDs = strings.Split(*snapshots, ",")
+	} else {
+		snapshotIDs, err = d.latestSnapshots()
+		if err != nil {
+			return err
+		}
+	}
+	if *asVolumes {
+		volumeIDs, err := d.RestoreAsVolumes(snapshotIDs)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(volumeIDs, ","))
+		return nil
+	}
+	return d.Restore(snapshotIDs)
+}
@@ -0,0 +1,96 @@
+package qingcloud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func snapshotAt(id string, t time.Time) *qcservice.Snapshot {
+	return &qcservice.Snapshot{SnapshotID: stringPtr(id), SnapshotTime: &t}
+}
+
+func TestLatestSnapshots(t *testing.T) {
+	now := time.Now()
+	c := newFakeClient(&qcservice.Instance{
+		InstanceID: stringPtr("i-test"),
+		VolumeIDs:  []*string{stringPtr("vol-a"), stringPtr("vol-b")},
+	})
+	c.snapshots["i-test"] = []*qcservice.Snapshot{
+		snapshotAt("ss-old", now.Add(-time.Hour)),
+		snapshotAt("ss-new", now),
+		{SnapshotID: stringPtr("ss-pending")},
+	}
+	c.snapshots["vol-a"] = []*qcservice.Snapshot{
+		snapshotAt("ss-a-new", now),
+		snapshotAt("ss-a-old", now.Add(-time.Hour)),
+	}
+	ids, err := newFakeDriver(c, "i-test").latestSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"ss-new", "ss-a-new"}; !reflect.DeepEqual(ids, expect) {
+		t.Errorf("expect latest snapshots %v, but get %v", expect, ids)
+	}
+
+	delete(c.snapshots, "i-test")
+	if _, err := newFakeDriver(c, "i-test").latestSnapshots(); err == nil {
+		t.Error("expect error when the instance has no snapshot")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c := newFakeClient(&qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(INSTANCE_STATUS_RUNNING)})
+	if err := newFakeDriver(c, "i-test").Restore([]string{"ss-1", "ss-2"}); err != nil {
+		t.Fatal(err)
+	}
+	stop, apply, start := c.index("StopInstanceWithin"), c.index("ApplySnapshots"), c.index("StartInstance")
+	if stop < 0 || !(stop < apply && apply < start) {
+		t.Errorf("expect stop, apply and start in order, but get %v", c.calls)
+	}
+	if !c.called("ApplySnapshots ss-1 ss-2") || c.index("CreateVolumeFromSnapshot") >= 0 {
+		t.Errorf("expect snapshots applied in place, but get %v", c.calls)
+	}
+}
+
+func TestRestoreAsVolumes(t *testing.T) {
+	c := newFakeClient(&qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(INSTANCE_STATUS_RUNNING)})
+	volumeIDs, err := newFakeDriver(c, "i-test").RestoreAsVolumes([]string{"ss-1", "ss-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"vol-ss-1", "vol-ss-2"}; !reflect.DeepEqual(volumeIDs, expect) {
+		t.Errorf("expect volumes %v, but get %v", expect, volumeIDs)
+	}
+	if c.index("ApplySnapshots") >= 0 || c.index("StopInstanceWithin") >= 0 {
+		t.Errorf("expect the instance untouched, but get %v", c.calls)
+	}
+}
+
+func TestRemoveSnapshotOnRemove(t *testing.T) {
+	c := newFakeClient(&qcservice.Instance{
+		InstanceID: stringPtr("i-test"),
+		Status:     stringPtr(INSTANCE_STATUS_RUNNING),
+		VolumeIDs:  []*string{stringPtr("vol-a")},
+	})
+	d := newFakeDriver(c, "i-test")
+	d.SnapshotOnRemove = true
+	if err := d.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, terminate := c.index("CreateSnapshots"), c.index("TerminateInstance")
+	if !c.called("CreateSnapshots i-test vol-a") || snapshot > terminate {
+		t.Errorf("expect final snapshot before terminate, but get %v", c.calls)
+	}
+
+	c = newFakeClient(&qcservice.Instance{InstanceID: stringPtr("i-test"), Status: stringPtr(INSTANCE_STATUS_RUNNING)})
+	c.errs["CreateSnapshots"] = errors.New("snapshot fail")
+	d = newFakeDriver(c, "i-test")
+	d.SnapshotOnRemove = true
+	if err := d.Remove(); err == nil || c.index("TerminateInstance") >= 0 {
+		t.Errorf("expect remove stops when the final snapshot fails, but get %v, %v", err, c.calls)
+	}
+}
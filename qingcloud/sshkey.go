@@ -0,0 +1,188 @@
+package qingcloud
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// publicKeyBlob returns the decoded key data of an authorized_keys format
+// public key, e.g. "ssh-rsa AAAAB3NzaC1yc2E... user@host". The key type is
+// read from the ssh wire format of the data, so any key type like ssh-ed25519
+// is supported, and options before the key type are skipped.
+func publicKeyBlob(publicKey string) ([]byte, error) {
+	fields := strings.Fields(publicKey)
+	for i := 0; i+1 < len(fields); i++ {
+		blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+		if err != nil {
+			continue
+		}
+		if keyType, ok := wireKeyType(blob); ok && keyType == fields[i] {
+			return blob, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid public key [%s]", publicKey)
+}
+
+// wireKeyType returns the key type, the first string of the key data in the
+// ssh wire format.
+func wireKeyType(blob []byte) (string, bool) {
+	if len(blob) < 4 {
+		return "", false
+	}
+	n := binary.BigEndian.Uint32(blob)
+	if n == 0 || uint64(n) > uint64(len(blob)-4) {
+		return "", false
+	}
+	return string(blob[4 : 4+n]), true
+}
+
+// fingerprint returns the SHA256 fingerprint of the key data, in the format
+// of "ssh-keygen -l".
+func fingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + strings.TrimRight(base64.StdEncoding.EncodeToString(sum[:]), "=")
+}
+
+// sshString encodes data in the ssh wire format.
+func sshString(buf *bytes.Buffer, data []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+// sshMPInt encodes a big int in the ssh wire format.
+func sshMPInt(buf *bytes.Buffer, n *big.Int) {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	sshString(buf, b)
+}
+
+// privateKeyToPublicKey derives the authorized_keys format public key from a
+// PEM encoded RSA or ECDSA private key.
+func privateKeyToPublicKey(privateKey []byte) (string, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return "", errors.New("No PEM encoded private key found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "OPENSSH PRIVATE KEY":
+		return "", errors.New("OPENSSH format private key is not supported, create the public key file with ssh-keygen -y")
+	default:
+		return "", fmt.Errorf("Unsupported private key type [%s]", block.Type)
+	}
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	var keyType string
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		keyType = "ssh-rsa"
+		sshString(buf, []byte(keyType))
+		sshMPInt(buf, big.NewInt(int64(k.E)))
+		sshMPInt(buf, k.N)
+	case *ecdsa.PrivateKey:
+		var curve string
+		switch k.Curve {
+		case elliptic.P256():
+			curve = "nistp256"
+		case elliptic.P384():
+			curve = "nistp384"
+		case elliptic.P521():
+			curve = "nistp521"
+		default:
+			return "", errors.New("Unsupported ECDSA curve")
+		}
+		keyType = "ecdsa-sha2-" + curve
+		sshString(buf, []byte(keyType))
+		sshString(buf, []byte(curve))
+		sshString(buf, elliptic.Marshal(k.Curve, k.X, k.Y))
+	default:
+		return "", fmt.Errorf("Unsupported private key %T", key)
+	}
+	return keyType + " " + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// readPublicKey reads the public key of the local ssh key, it is derived from
+// the private key only if there is no public key file, which supports RSA and
+// ECDSA keys.
+func (d *Driver) readPublicKey() (string, error) {
+	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err == nil {
+		pub := strings.TrimSpace(string(publicKey))
+		if _, err := publicKeyBlob(pub); err != nil {
+			return "", fmt.Errorf("Read public key [%s] error: %s", d.publicSSHKeyPath(), err.Error())
+		}
+		return pub, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	privateKey, err := ioutil.ReadFile(d.GetSSHKeyPath())
+	if err != nil {
+		return "", err
+	}
+	pub, err := privateKeyToPublicKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("Derive public key from [%s] error: %s", d.GetSSHKeyPath(), err.Error())
+	}
+	return pub, nil
+}
+
+// samePublicKey returns true if both public keys have the same key data,
+// comments are ignored.
+func samePublicKey(a, b string) bool {
+	blobA, err := publicKeyBlob(a)
+	if err != nil {
+		return false
+	}
+	blobB, err := publicKeyBlob(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(blobA, blobB)
+}
+
+// checkLoginKeyPair returns an error if the local ssh key doesn't match the
+// public key of the login keypair.
+func (d *Driver) checkLoginKeyPair(keyPairPublicKey string) error {
+	publicKey, err := d.readPublicKey()
+	if err != nil {
+		return err
+	}
+	localBlob, err := publicKeyBlob(publicKey)
+	if err != nil {
+		return err
+	}
+	remoteBlob, err := publicKeyBlob(keyPairPublicKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(localBlob, remoteBlob) {
+		return fmt.Errorf("SSH key [%s] with fingerprint [%s] doesn't match qingcloud-login-keypair [%s] with fingerprint [%s].",
+			d.GetSSHKeyPath(), fingerprint(localBlob), d.LoginKeyPair, fingerprint(remoteBlob))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package qingcloud
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+var mountPointPattern = regexp.MustCompile(`^/[A-Za-z0-9._/-]*$`)
+
+// sharedStorageSpec is a shared storage given as target-id:mountpoint.
+type sharedStorageSpec struct {
+	Target     string
+	MountPoint string
+}
+
+func parseSharedStorageSpec(s string) (*sharedStorageSpec, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("Invalid shared storage [%s], expect target-id:mountpoint.", s)
+	}
+	if !mountPointPattern.MatchString(parts[1]) {
+		return nil, fmt.Errorf("Invalid mountpoint [%s] of shared storage [%s], expect an absolute path.", parts[1], s)
+	}
+	return &sharedStorageSpec{Target: parts[0], MountPoint: parts[1]}, nil
+}
+
+// nfsSource returns the NFS export of the shared target, server-ip:/export.
+func (d *Driver) nfsSource(spec *sharedStorageSpec) (string, error) {
+	client := d.GetClient()
+	target, err := client.DescribeS2SharedTarget(&spec.Target)
+	if err != nil {
+		return "", err
+	}
+	switch stringValue(target.TargetType) {
+	case "NFS":
+	case "ISCSI":
+		return "", fmt.Errorf("Shared target [%s] is an iSCSI target, iSCSI targets are not supported, only NFS targets can be mounted.", spec.Target)
+	default:
+		return "", fmt.Errorf("Shared target [%s] is of type [%s], only NFS targets can be mounted.", spec.Target, stringValue(target.TargetType))
+	}
+	server, err := client.DescribeS2Server(target.S2ServerID)
+	if err != nil {
+		return "", err
+	}
+	if server.PrivateIP == nil || *server.PrivateIP == "" {
+		return "", fmt.Errorf("S2 server [%s] of shared target [%s] has no IP address.", stringValue(target.S2ServerID), spec.Target)
+	}
+	export := stringValue(target.ExportName)
+	if !strings.HasPrefix(export, "/") {
+		export = "/" + export
+	}
+	return *server.PrivateIP + ":" + export, nil
+}
+
+// checkSharedStorage validates qingcloud-shared-storage.
+func (d *Driver) checkSharedStorage() []error {
+	errs := []error{}
+	for _, s := range d.SharedStorage {
+		spec, err := parseSharedStorageSpec(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := d.nfsSource(spec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// nfsMountCommand installs the NFS client, adds the mount to /etc/fstab so it
+// survives reboots, and mounts it.
+func nfsMountCommand(source, mountPoint string) string {
+	entry := fmt.Sprintf("%s %s nfs defaults,_netdev 0 0", source, mountPoint)
+	return strings.Join([]string{
+		"(which mount.nfs || apt-get install -y nfs-common)",
+		"mkdir -p " + mountPoint,
+		fmt.Sprintf("(grep -q ' %s nfs ' /etc/fstab || echo '%s' >> /etc/fstab)", mountPoint, entry),
+		fmt.Sprintf("(mountpoint -q %s || mount %s)", mountPoint, mountPoint),
+	}, " && ")
+}
+
+// mountSharedStorage mounts the qingcloud-shared-storage targets over ssh.
+func (d *Driver) mountSharedStorage() error {
+	for _, s := range d.SharedStorage {
+		spec, err := parseSharedStorageSpec(s)
+		if err != nil {
+			return err
+		}
+		source, err := d.nfsSource(spec)
+		if err != nil {
+			return err
+		}
+		log.Infof("Mounting shared storage [%s] on [%s] of Instance [%s]...", source, spec.MountPoint, *d.InstanceID)
+		if _, err := drivers.RunSSHCommandFromDriver(d, nfsMountCommand(source, spec.MountPoint)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
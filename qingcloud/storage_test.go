@@ -0,0 +1,59 @@
+package qingcloud
+
+import (
+	"strings"
+	"testing"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestParseSharedStorageSpec(t *testing.T) {
+	spec, err := parseSharedStorageSpec("s2t-abc:/mnt/cache")
+	if err != nil || spec.Target != "s2t-abc" || spec.MountPoint != "/mnt/cache" {
+		t.Errorf("unexpected spec %+v, err: %v", spec, err)
+	}
+	for _, s := range []string{"", "s2t-abc", ":/mnt/cache", "s2t-abc:mnt", "s2t-abc:/mnt/a b", "s2t-abc:/mnt/'x"} {
+		if _, err := parseSharedStorageSpec(s); err == nil {
+			t.Errorf("expect error for [%s]", s)
+		}
+	}
+}
+
+func TestNFSMountCommand(t *testing.T) {
+	cmd := nfsMountCommand("172.16.0.5:/mnt/cache", "/cache")
+	if !strings.Contains(cmd, "echo '172.16.0.5:/mnt/cache /cache nfs defaults,_netdev 0 0' >> /etc/fstab") {
+		t.Errorf("expect fstab entry in [%s]", cmd)
+	}
+	if !strings.Contains(cmd, "mount /cache") {
+		t.Errorf("expect mount in [%s]", cmd)
+	}
+}
+
+func TestCheckSharedStorage(t *testing.T) {
+	c := newFakeClient()
+	c.s2Targets["s2t-nfs"] = &qcservice.S2SharedTarget{TargetType: stringPtr("NFS"), S2ServerID: stringPtr("s2-1"), ExportName: stringPtr("mnt/cache")}
+	c.s2Targets["s2t-iscsi"] = &qcservice.S2SharedTarget{TargetType: stringPtr("ISCSI"), S2ServerID: stringPtr("s2-1")}
+	c.s2Servers["s2-1"] = &qcservice.S2Server{PrivateIP: stringPtr("172.16.0.5")}
+	d := newFakeDriver(c, "")
+	d.SharedStorage = []string{"s2t-nfs:/cache"}
+	if errs := d.checkSharedStorage(); len(errs) != 0 {
+		t.Errorf("expect NFS target accepted, but get %v", errs)
+	}
+	source, err := d.nfsSource(&sharedStorageSpec{Target: "s2t-nfs", MountPoint: "/cache"})
+	if err != nil || source != "172.16.0.5:/mnt/cache" {
+		t.Errorf("expect source [172.16.0.5:/mnt/cache], but get [%s], err: %v", source, err)
+	}
+
+	c.calls = nil
+	d.SharedStorage = []string{"s2t-iscsi:/data", "s2t-missing:/tmp/x", "s2t-nfs:data"}
+	errs := d.checkSharedStorage()
+	if len(errs) != 3 {
+		t.Fatalf("expect 3 errors, but get %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "iSCSI targets are not supported") {
+		t.Errorf("expect iSCSI target rejected, but get [%s]", errs[0])
+	}
+	if c.index("DescribeS2Server") >= 0 {
+		t.Errorf("expect no S2 server lookup for the iSCSI target, but get calls %v", c.calls)
+	}
+}
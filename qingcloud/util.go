@@ -1,5 +1,9 @@
 package qingcloud
 
+import (
+	"strings"
+)
+
 func stringPtr(str string) *string {
 	return &str
 }
@@ -7,3 +11,30 @@ func stringPtr(str string) *string {
 func intPtr(i int) *int {
 	return &i
 }
+
+func stringValue(str *string) string {
+	if str == nil {
+		return ""
+	}
+	return *str
+}
+
+// multiError collects the errors of independent steps, so all of them can be
+// reported together.
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// errorOrNil returns nil if no error is collected.
+func (e multiError) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
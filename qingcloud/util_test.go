@@ -0,0 +1,16 @@
+package qingcloud
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	if err := (multiError{}).errorOrNil(); err != nil {
+		t.Errorf("expect nil, but get %v", err)
+	}
+	err := multiError{errors.New("a"), errors.New("b")}.errorOrNil()
+	if err == nil || err.Error() != "a\nb" {
+		t.Errorf("expect all errors reported, but get %v", err)
+	}
+}
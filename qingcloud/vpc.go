@@ -0,0 +1,152 @@
+package qingcloud
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const defaultVPCName = "docker-machine"
+
+// checkVPC validates qingcloud-create-vpc.
+func (d *Driver) checkVPC() error {
+	if _, _, err := net.ParseCIDR(d.CreateVPC); err != nil {
+		return fmt.Errorf("Invalid qingcloud-create-vpc [%s]: %s", d.CreateVPC, err.Error())
+	}
+	if d.VxNet != "" && d.VxNet != defaultVxNet {
+		return errors.New("Param error: qingcloud-create-vpc param can not work with qingcloud-vxnet-id param.")
+	}
+	return nil
+}
+
+// ensureVPC finds the router named qingcloud-vpc-name and its vxnet of the
+// qingcloud-create-vpc network, and creates what is missing: a router with an
+// EIP, and a vxnet joined to it. The machine is created in the vxnet.
+func (d *Driver) ensureVPC() error {
+	client := d.GetClient()
+	name := d.VPCName
+	if name == "" {
+		name = defaultVPCName
+	}
+	routers, err := client.ListRouters(false)
+	if err != nil {
+		return err
+	}
+	var routerID *string
+	for _, router := range routers {
+		if router.RouterName != nil && *router.RouterName == name {
+			routerID = router.RouterID
+			break
+		}
+	}
+	if routerID == nil {
+		log.Infof("Creating Router [%s]...", name)
+		routerID, err = client.CreateRouter(&name)
+		if err != nil {
+			return err
+		}
+		d.tagResource("router", routerID)
+		eip, err := client.AllocateEIP(&name)
+		if err != nil {
+			return err
+		}
+		d.tagResource("eip", eip.EIPID)
+		if err := client.SetRouterEIP(routerID, eip.EIPID); err != nil {
+			return err
+		}
+		log.Infof("Created Router [%s] with EIP [%s]", *routerID, stringValue(eip.EIPAddr))
+	}
+	d.Router = *routerID
+
+	vxnets, err := client.DescribeRouterVxNets(routerID)
+	if err != nil {
+		return err
+	}
+	for _, vxnet := range vxnets {
+		if vxnet.IPNetwork != nil && *vxnet.IPNetwork == d.CreateVPC && vxnet.VxNetID != nil {
+			log.Infof("Use VxNet [%s] of Router [%s] for network [%s]", *vxnet.VxNetID, *routerID, d.CreateVPC)
+			d.VxNet = *vxnet.VxNetID
+			return nil
+		}
+	}
+	log.Infof("Creating VxNet [%s] for network [%s]...", name, d.CreateVPC)
+	vxnetID, err := client.CreateVxNet(&name)
+	if err != nil {
+		return err
+	}
+	d.tagResource("vxnet", vxnetID)
+	if err := client.JoinRouter(routerID, vxnetID, &d.CreateVPC); err != nil {
+		return err
+	}
+	log.Infof("Joined VxNet [%s] to Router [%s]", *vxnetID, *routerID)
+	d.VxNet = *vxnetID
+	return nil
+}
+
+// vpcNetwork is a router created by the driver and the vxnets joined to it.
+type vpcNetwork struct {
+	RouterID string
+	Name     string
+	EIPID    string
+	VxNetIDs []string
+	// CreateTime is the create time of the router.
+	CreateTime *time.Time
+	// Instances is the number of instances in the vxnets.
+	Instances int
+}
+
+// loadVPCNetworks describes the routers with the driver tag.
+func loadVPCNetworks(client Client) ([]*vpcNetwork, error) {
+	routers, err := client.ListRouters(true)
+	if err != nil {
+		return nil, err
+	}
+	networks := []*vpcNetwork{}
+	for _, router := range routers {
+		n := &vpcNetwork{RouterID: *router.RouterID, Name: stringValue(router.RouterName), VxNetIDs: []string{}, CreateTime: router.CreateTime}
+		if router.EIP != nil {
+			n.EIPID = stringValue(router.EIP.EIPID)
+		}
+		vxnets, err := client.DescribeRouterVxNets(router.RouterID)
+		if err != nil {
+			return nil, err
+		}
+		for _, vxnet := range vxnets {
+			count, err := client.CountVxNetInstances(vxnet.VxNetID)
+			if err != nil {
+				return nil, err
+			}
+			n.VxNetIDs = append(n.VxNetIDs, *vxnet.VxNetID)
+			n.Instances += count
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+// deleteVPCNetwork removes the vxnets from the router, and deletes them, the
+// router and its EIP.
+func deleteVPCNetwork(client Client, n *vpcNetwork) error {
+	if len(n.VxNetIDs) > 0 {
+		vxnetIDs := []*string{}
+		for _, id := range n.VxNetIDs {
+			vxnetIDs = append(vxnetIDs, stringPtr(id))
+		}
+		if err := client.LeaveRouter(&n.RouterID, vxnetIDs); err != nil {
+			return err
+		}
+		if err := client.DeleteVxNets(vxnetIDs); err != nil {
+			return err
+		}
+	}
+	if err := client.DeleteRouter(&n.RouterID); err != nil {
+		return err
+	}
+	if n.EIPID != "" {
+		return client.ReleaseEIP(&n.EIPID)
+	}
+	return nil
+}
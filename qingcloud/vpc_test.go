@@ -0,0 +1,109 @@
+package qingcloud
+
+import (
+	"testing"
+
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestEnsureVPCReuse(t *testing.T) {
+	c := newFakeClient()
+	c.routers = []*qcservice.Router{{RouterID: stringPtr("rtr-other"), RouterName: stringPtr("other")}, {RouterID: stringPtr("rtr-1"), RouterName: stringPtr("dev")}}
+	c.routerVxNets["rtr-1"] = []*qcservice.RouterVxNet{
+		{VxNetID: stringPtr("vxnet-2"), IPNetwork: stringPtr("192.168.2.0/24")},
+		{VxNetID: stringPtr("vxnet-1"), IPNetwork: stringPtr("192.168.1.0/24")},
+	}
+	d := newFakeDriver(c, "")
+	d.VPCName = "dev"
+	d.CreateVPC = "192.168.1.0/24"
+	if err := d.ensureVPC(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Router != "rtr-1" || d.VxNet != "vxnet-1" {
+		t.Errorf("expect router rtr-1 and vxnet vxnet-1, but get [%s] [%s]", d.Router, d.VxNet)
+	}
+	for _, method := range []string{"CreateRouter", "AllocateEIP", "CreateVxNet", "JoinRouter", "TagResources"} {
+		if c.index(method) >= 0 {
+			t.Errorf("expect existing network reused, but get calls %v", c.calls)
+		}
+	}
+}
+
+func TestEnsureVPCCreate(t *testing.T) {
+	c := newFakeClient()
+	d := newFakeDriver(c, "")
+	d.CreateVPC = "192.168.1.0/24"
+	if err := d.ensureVPC(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Router != "rtr-docker-machine" || d.VxNet != "vxnet-docker-machine" {
+		t.Errorf("expect router and vxnet created with the default name, but get [%s] [%s]", d.Router, d.VxNet)
+	}
+	for _, call := range []string{
+		"CreateRouter docker-machine",
+		"TagResources " + DefaultTagName + " router rtr-docker-machine",
+		"AllocateEIP docker-machine",
+		"TagResources " + DefaultTagName + " eip eip-docker-machine",
+		"SetRouterEIP rtr-docker-machine eip-docker-machine",
+		"CreateVxNet docker-machine",
+		"TagResources " + DefaultTagName + " vxnet vxnet-docker-machine",
+		"JoinRouter rtr-docker-machine vxnet-docker-machine 192.168.1.0/24",
+	} {
+		if !c.called(call) {
+			t.Errorf("expect [%s], but get calls %v", call, c.calls)
+		}
+	}
+
+	// a second machine joins the created network
+	c.calls = nil
+	d = newFakeDriver(c, "")
+	d.CreateVPC = "192.168.1.0/24"
+	if err := d.ensureVPC(); err != nil {
+		t.Fatal(err)
+	}
+	if d.VxNet != "vxnet-docker-machine" || c.index("CreateRouter") >= 0 || c.index("CreateVxNet") >= 0 {
+		t.Errorf("expect the created network reused, but get vxnet [%s] calls %v", d.VxNet, c.calls)
+	}
+
+	// a new network of the router only gets a vxnet
+	c.calls = nil
+	d = newFakeDriver(c, "")
+	d.CreateVPC = "192.168.2.0/24"
+	if err := d.ensureVPC(); err != nil {
+		t.Fatal(err)
+	}
+	if c.index("CreateRouter") >= 0 || !c.called("JoinRouter rtr-docker-machine vxnet-docker-machine 192.168.2.0/24") {
+		t.Errorf("expect a vxnet joined to the existing router, but get calls %v", c.calls)
+	}
+}
+
+func TestDeleteVPCNetwork(t *testing.T) {
+	c := newFakeClient()
+	n := &vpcNetwork{RouterID: "rtr-1", EIPID: "eip-1", VxNetIDs: []string{"vxnet-1", "vxnet-2"}}
+	if err := deleteVPCNetwork(c, n); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"LeaveRouter rtr-1 vxnet-1 vxnet-2",
+		"DeleteVxNets vxnet-1 vxnet-2",
+		"DeleteRouter rtr-1",
+		"ReleaseEIP eip-1",
+	}
+	if len(c.calls) != len(expect) {
+		t.Fatalf("expect calls %v, but get %v", expect, c.calls)
+	}
+	for i, call := range expect {
+		if c.calls[i] != call {
+			t.Errorf("expect call %d [%s], but get [%s]", i, call, c.calls[i])
+		}
+	}
+
+	// a router without vxnets or EIP is only deleted
+	c = newFakeClient()
+	if err := deleteVPCNetwork(c, &vpcNetwork{RouterID: "rtr-2", VxNetIDs: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.calls) != 1 || c.calls[0] != "DeleteRouter rtr-2" {
+		t.Errorf("expect only DeleteRouter, but get calls %v", c.calls)
+	}
+}
@@ -0,0 +1,141 @@
+package qingcloud
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	qcerrors "github.com/yunify/qingcloud-sdk-go/request/errors"
+)
+
+const (
+	// retCodeQuotaExceeded is returned when the resource quota is used up.
+	retCodeQuotaExceeded = 2500
+	// retCodeResourceInsufficient is returned when the zone is out of capacity.
+	retCodeResourceInsufficient = 5200
+)
+
+// splitZones splits a comma separated zone list, e.g. "pek3a,pek3b,sh1a".
+func splitZones(zones string) []string {
+	result := []string{}
+	for _, zone := range strings.Split(zones, ",") {
+		zone = strings.TrimSpace(zone)
+		if zone != "" {
+			result = append(result, zone)
+		}
+	}
+	return result
+}
+
+// isCapacityError returns true if the error means another zone may succeed.
+func isCapacityError(err error) bool {
+	qcErr, ok := err.(*qcerrors.QingCloudError)
+	if !ok {
+		return false
+	}
+	return qcErr.RetCode == retCodeQuotaExceeded || qcErr.RetCode == retCodeResourceInsufficient
+}
+
+// rollbackInstance removes the instance and the resources created for it in
+// the current zone, so the create can be retried in another zone.
+func (d *Driver) rollbackInstance() {
+	client := d.GetClient()
+	if d.InstanceID != nil {
+		if err := client.TerminateInstance(d.InstanceID); err != nil {
+			log.Errorf("Terminate Instance [%s] fail, err: [%s]", *d.InstanceID, err.Error())
+		}
+		d.InstanceID = nil
+	}
+	if d.EIP != nil {
+		if err := client.ReleaseEIP(d.EIP.EIPID); err != nil {
+			log.Errorf("Release EIP [%s] fail, err: [%s]", *d.EIP.EIPID, err.Error())
+		}
+		d.EIP = nil
+	}
+	if d.SecurityGroup != nil {
+		if err := client.DeleteSecurityGroup(d.SecurityGroup.SecurityGroupID); err != nil {
+			log.Errorf("Delete SecurityGroup [%s] fail, err: [%s]", *d.SecurityGroup.SecurityGroupID, err.Error())
+		}
+		d.SecurityGroup = nil
+	}
+}
+
+// createInZones creates the instance in the current zone, and fails over to
+// the next zones of qingcloud-zones when a zone is out of capacity or quota.
+func (d *Driver) createInZones() error {
+	err := d.createInstance()
+	if err != nil && isCapacityError(err) && len(d.Zones) > 1 {
+		if scopedErr := d.checkZoneScoped(); scopedErr != nil {
+			log.Warnf("Create Instance in zone [%s] fail, and %s", d.Zone, scopedErr.Error())
+			return err
+		}
+	}
+	for i := 1; err != nil && isCapacityError(err) && i < len(d.Zones); i++ {
+		log.Warnf("Create Instance in zone [%s] fail, err: [%s], try zone [%s]", d.Zone, err.Error(), d.Zones[i])
+		if err := d.switchZone(d.Zones[i]); err != nil {
+			return err
+		}
+		err = d.createInstance()
+	}
+	return err
+}
+
+// checkZoneScoped returns an error if the machine uses resources which only
+// exist in the current zone, so it can not fail over.
+func (d *Driver) checkZoneScoped() error {
+	if d.VxNet != defaultVxNet {
+		return fmt.Errorf("VxNet [%s] only exists in zone [%s], can not fail over.", d.VxNet, d.Zone)
+	}
+	if len(d.ExtraNics) > 0 {
+		return fmt.Errorf("VxNets of qingcloud-extra-nic only exist in zone [%s], can not fail over.", d.Zone)
+	}
+	if len(d.ExtraKeyPairs) > 0 {
+		return fmt.Errorf("KeyPairs of qingcloud-extra-keypair only exist in zone [%s], can not fail over.", d.Zone)
+	}
+	if len(d.SharedStorage) > 0 {
+		return fmt.Errorf("Targets of qingcloud-shared-storage only exist in zone [%s], can not fail over.", d.Zone)
+	}
+	return nil
+}
+
+// switchZone moves the machine to be created to another zone. Zone scoped
+// inputs are translated: the keypair is recreated with the same public key
+// and the image selector is resolved again.
+func (d *Driver) switchZone(zone string) error {
+	if err := d.checkZoneScoped(); err != nil {
+		return err
+	}
+	d.rollbackInstance()
+
+	var keyPairName, publicKey *string
+	if d.LoginKeyPair != "" {
+		keyPair, err := d.GetClient().DescribeKeyPair(&d.LoginKeyPair)
+		if err != nil {
+			return err
+		}
+		keyPairName, publicKey = keyPair.KeyPairName, keyPair.PubKey
+		if keyPairName == nil {
+			keyPairName = stringPtr(d.MachineName)
+		}
+		if d.KeyPairCreated {
+			if err := d.GetClient().DeleteKeyPair(&d.LoginKeyPair); err != nil {
+				log.Errorf("Delete KeyPair [%s] fail, err: [%s]", d.LoginKeyPair, err.Error())
+			}
+		}
+	}
+
+	d.Zone = zone
+	d.client = nil
+
+	if publicKey != nil {
+		keyPairID, err := d.GetClient().CreateKeyPair(keyPairName, publicKey)
+		if err != nil {
+			return err
+		}
+		log.Infof("Created KeyPair [%s] in zone [%s] for [%s]", *keyPairID, zone, d.LoginKeyPair)
+		d.LoginKeyPair = *keyPairID
+		d.KeyPairCreated = true
+		d.tagResource("keypair", keyPairID)
+	}
+	return d.resolveImage()
+}
@@ -0,0 +1,110 @@
+package qingcloud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	qcerrors "github.com/yunify/qingcloud-sdk-go/request/errors"
+	qcservice "github.com/yunify/qingcloud-sdk-go/service"
+)
+
+func TestSplitZones(t *testing.T) {
+	zones := splitZones("pek3a, pek3b,,sh1a")
+	if !reflect.DeepEqual(zones, []string{"pek3a", "pek3b", "sh1a"}) {
+		t.Errorf("unexpected zones %v", zones)
+	}
+	if zones := splitZones(""); len(zones) != 0 {
+		t.Errorf("expect no zones, but get %v", zones)
+	}
+}
+
+func TestIsCapacityError(t *testing.T) {
+	if !isCapacityError(&qcerrors.QingCloudError{RetCode: retCodeResourceInsufficient}) {
+		t.Error("expect resource insufficient is capacity error")
+	}
+	if !isCapacityError(&qcerrors.QingCloudError{RetCode: retCodeQuotaExceeded}) {
+		t.Error("expect quota exceeded is capacity error")
+	}
+	if isCapacityError(&qcerrors.QingCloudError{RetCode: 1100}) {
+		t.Error("expect parameter error is not capacity error")
+	}
+	if isCapacityError(errors.New("timeout")) {
+		t.Error("expect plain error is not capacity error")
+	}
+}
+
+// newFailoverDriver returns a driver in zone za failing over to zb, whose
+// instance can not be created in za.
+func newFailoverDriver() (*Driver, map[string]*fakeClient) {
+	clients := map[string]*fakeClient{"za": newFakeClient(), "zb": newFakeClient()}
+	clients["za"].errs["RunInstance"] = &qcerrors.QingCloudError{RetCode: retCodeResourceInsufficient, Message: "insufficient"}
+	for _, c := range clients {
+		c.keyPairs["kp-user"] = &qcservice.KeyPair{KeyPairID: stringPtr("kp-user"), KeyPairName: stringPtr("user"), PubKey: stringPtr(testPublicKeyA)}
+		c.images["img-test"] = &qcservice.Image{ImageID: stringPtr("img-test"), ImageName: stringPtr("test")}
+	}
+	d := newFakeDriver(clients["za"], "")
+	d.Zone = "za"
+	d.Zones = []string{"za", "zb"}
+	d.VxNet = defaultVxNet
+	d.LoginKeyPair = "kp-user"
+	d.Image = "img-test"
+	return d, clients
+}
+
+func TestCreateInZonesZoneScoped(t *testing.T) {
+	for name, scope := range map[string]func(d *Driver){
+		"extra keypair":  func(d *Driver) { d.ExtraKeyPairs = []string{"kp-extra"} },
+		"shared storage": func(d *Driver) { d.SharedStorage = []string{"s2-target"} },
+	} {
+		d, clients := newFailoverDriver()
+		defer useZoneClients(clients)()
+		scope(d)
+		err := d.createInZones()
+		if !isCapacityError(err) {
+			t.Errorf("%s: expect the capacity error of zone za, but get %v", name, err)
+		}
+		if d.Zone != "za" || len(clients["zb"].calls) > 0 {
+			t.Errorf("%s: expect no failover, but get zone %s and calls %v", name, d.Zone, clients["zb"].calls)
+		}
+	}
+}
+
+func TestCreateInZonesFailover(t *testing.T) {
+	d, clients := newFailoverDriver()
+	defer useZoneClients(clients)()
+	// the instance runs in za but its EIP quota is used up
+	delete(clients["za"].errs, "RunInstance")
+	clients["za"].errs["BindEIP"] = &qcerrors.QingCloudError{RetCode: retCodeQuotaExceeded, Message: "quota exceeded"}
+	clients["za"].keyPairs["kp-created"] = &qcservice.KeyPair{KeyPairID: stringPtr("kp-created"), KeyPairName: stringPtr("test"), PubKey: stringPtr(testPublicKeyA)}
+	d.LoginKeyPair = "kp-created"
+	d.KeyPairCreated = true
+	if err := d.createInZones(); err != nil {
+		t.Fatal(err)
+	}
+	za, zb := clients["za"], clients["zb"]
+	if !za.called("TerminateInstance i-1") || !za.called("DeleteKeyPair kp-created") {
+		t.Errorf("expect the instance and created keypair of za removed, but get calls %v", za.calls)
+	}
+	if !zb.called("CreateKeyPair test") || !zb.called("RunInstance img-test kp-test") {
+		t.Errorf("expect the keypair copied and the instance run in zb, but get calls %v", zb.calls)
+	}
+	if d.Zone != "zb" || d.LoginKeyPair != "kp-test" || !d.KeyPairCreated ||
+		stringValue(d.InstanceID) != "i-1" || stringValue(d.EIP.EIPID) != "eip-i-1" || d.IPAddress != "1.2.3.4" {
+		t.Errorf("expect the machine saved in zb, but get %+v", d)
+	}
+}
+
+func TestCreateInZonesKeepsUserKeyPair(t *testing.T) {
+	d, clients := newFailoverDriver()
+	defer useZoneClients(clients)()
+	if err := d.createInZones(); err != nil {
+		t.Fatal(err)
+	}
+	if clients["za"].index("DeleteKeyPair") >= 0 {
+		t.Errorf("expect the keypair of the user kept, but get calls %v", clients["za"].calls)
+	}
+	if d.Zone != "zb" || d.LoginKeyPair != "kp-user" || !d.KeyPairCreated {
+		t.Errorf("expect the keypair copied to zb, but get keypair [%s] in zone [%s]", d.LoginKeyPair, d.Zone)
+	}
+}